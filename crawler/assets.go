@@ -0,0 +1,258 @@
+package crawler
+
+import (
+	"golang.org/x/net/html"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Asset types
+const (
+	ASSET_IMAGE      = "image"
+	ASSET_SCRIPT     = "script"
+	ASSET_STYLESHEET = "stylesheet"
+	ASSET_FONT       = "font"
+	ASSET_IFRAME     = "iframe"
+	ASSET_EMBED      = "embed" // <embed> and <object>
+	ASSET_VIDEO      = "video"
+	ASSET_AUDIO      = "audio"
+	ASSET_DOCUMENT   = "document" // links to downloadable files
+)
+
+// Extensions of the links classified as ASSET_DOCUMENT
+var DOCUMENT_EXTENSIONS = []string{".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".odt", ".ods",
+	".odp", ".rtf", ".txt", ".csv", ".zip", ".rar", ".7z", ".gz", ".tar", ".epub"}
+
+// Extensions of the font files
+var FONT_EXTENSIONS = []string{".woff", ".woff2", ".ttf", ".otf", ".eot"}
+
+// Attributes of lazy loaded images
+var LAZY_SRC_ATTRS = []string{"data-src", "data-lazy-src", "data-original"}
+var LAZY_SRCSET_ATTRS = []string{"data-srcset", "data-lazy-srcset"}
+
+// Resource used or linked by the page
+type Asset struct {
+	Url  string `json:"url"`  // resolved url
+	Type string `json:"type"` // one of ASSET_*
+}
+
+// Asset of the site with the number of pages it's found on
+type AssetCount struct {
+	Asset
+	PagesNum int `json:"pagesNum"`
+}
+
+// Images(src, srcset, <picture>, lazy loading attributes), scripts, stylesheets, fonts, iframes, embeds,
+// video, audio and links to downloadable documents. Assets are unique within the page
+type AssetsExtractor struct{}
+
+func (AssetsExtractor) Name() string { return "assets" }
+
+func (AssetsExtractor) Start(doc *Document) PageExtraction { return &assetsExtraction{doc: doc} }
+
+type assetsExtraction struct {
+	doc    *Document
+	assets []Asset // with raw urls
+	media  []string
+}
+
+func (e *assetsExtraction) add(assetType string, link string) {
+	if link = strings.TrimSpace(link); link != "" {
+		e.assets = append(e.assets, Asset{Url: link, Type: assetType})
+	}
+}
+
+func (e *assetsExtraction) addAttr(assetType string, token html.Token, key string) {
+	if value, ok := tokenAttr(token, key); ok {
+		e.add(assetType, value)
+	}
+}
+
+func (e *assetsExtraction) addSrcset(assetType string, token html.Token, key string) {
+	if value, ok := tokenAttr(token, key); ok {
+		for _, link := range parseSrcset(value) {
+			e.add(assetType, link)
+		}
+	}
+}
+
+func (e *assetsExtraction) Token(token html.Token) {
+	if token.Type == html.EndTagToken && (token.Data == "video" || token.Data == "audio") && len(e.media) > 0 {
+		e.media = e.media[:len(e.media)-1]
+	}
+	if token.Type != html.StartTagToken && token.Type != html.SelfClosingTagToken {
+		return
+	}
+
+	switch token.Data {
+	case "img":
+		e.addAttr(ASSET_IMAGE, token, "src")
+		e.addSrcset(ASSET_IMAGE, token, "srcset")
+		for _, attr := range LAZY_SRC_ATTRS {
+			e.addAttr(ASSET_IMAGE, token, attr)
+		}
+		for _, attr := range LAZY_SRCSET_ATTRS {
+			e.addSrcset(ASSET_IMAGE, token, attr)
+		}
+	case "source":
+		// <picture> sources have srcset, media sources have src
+		mediaType := ASSET_IMAGE
+		if len(e.media) > 0 {
+			mediaType = e.media[len(e.media)-1]
+		}
+		e.addAttr(mediaType, token, "src")
+		e.addSrcset(ASSET_IMAGE, token, "srcset")
+		for _, attr := range LAZY_SRCSET_ATTRS {
+			e.addSrcset(ASSET_IMAGE, token, attr)
+		}
+	case "video", "audio":
+		if token.Type == html.StartTagToken {
+			e.media = append(e.media, token.Data)
+		}
+		e.addAttr(token.Data, token, "src")
+		e.addAttr(ASSET_IMAGE, token, "poster")
+	case "script":
+		e.addAttr(ASSET_SCRIPT, token, "src")
+	case "iframe", "frame":
+		e.addAttr(ASSET_IFRAME, token, "src")
+	case "embed":
+		e.addAttr(ASSET_EMBED, token, "src")
+	case "object":
+		e.addAttr(ASSET_EMBED, token, "data")
+	case "link":
+		e.linkTag(token)
+	case "a":
+		if href, ok := tokenAttr(token, "href"); ok && hasExtension(href, DOCUMENT_EXTENSIONS) {
+			e.add(ASSET_DOCUMENT, href)
+		}
+	}
+}
+
+// <link> of stylesheets, icons and preloaded resources
+func (e *assetsExtraction) linkTag(token html.Token) {
+	href, ok := tokenAttr(token, "href")
+	if !ok {
+		return
+	}
+	rel, _ := tokenAttr(token, "rel")
+	rels := strings.Fields(strings.ToLower(rel))
+
+	for _, r := range rels {
+		switch r {
+		case "stylesheet":
+			e.add(ASSET_STYLESHEET, href)
+			return
+		case "icon", "apple-touch-icon", "apple-touch-icon-precomposed":
+			e.add(ASSET_IMAGE, href)
+			return
+		case "preload", "prefetch", "modulepreload":
+			as, _ := tokenAttr(token, "as")
+			switch strings.ToLower(strings.TrimSpace(as)) {
+			case "font":
+				e.add(ASSET_FONT, href)
+			case "style":
+				e.add(ASSET_STYLESHEET, href)
+			case "script":
+				e.add(ASSET_SCRIPT, href)
+			case "image":
+				e.add(ASSET_IMAGE, href)
+			default:
+				if r == "modulepreload" {
+					e.add(ASSET_SCRIPT, href)
+				} else if hasExtension(href, FONT_EXTENSIONS) {
+					e.add(ASSET_FONT, href)
+				}
+			}
+			return
+		}
+	}
+}
+
+func (e *assetsExtraction) Finish(page *CrawledPage) (any, error) {
+	seen := make(map[Asset]struct{})
+	for _, asset := range e.assets {
+		link, err := ResolveLink(e.doc.Base, asset.Url)
+		if err != nil {
+			continue
+		}
+		asset.Url = link
+		if _, ok := seen[asset]; ok {
+			continue
+		}
+		seen[asset] = struct{}{}
+		page.Assets = append(page.Assets, asset)
+	}
+	return nil, nil
+}
+
+// Returns urls of the image candidates: "a.png 1x, b.png 2x" or "a.png 480w, b.png 800w".
+// Urls may contain commas, a candidate ends with a comma after the url or its descriptors
+func parseSrcset(srcset string) (links []string) {
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return links
+		}
+
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		link := s[:end]
+		s = s[end:]
+
+		if strings.HasSuffix(link, ",") {
+			link = strings.TrimRight(link, ",")
+		} else if comma := strings.Index(s, ","); comma >= 0 { // skip descriptors
+			s = s[comma+1:]
+		} else {
+			s = ""
+		}
+		links = append(links, link)
+	}
+}
+
+func hasExtension(link string, extensions []string) bool {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return false
+	}
+	return contains(extensions, strings.ToLower(path.Ext(u.Path)))
+}
+
+// Returns unique assets of the crawled pages with the number of pages they are found on,
+// the most used assets go first. Pages redirected to the same url are counted once
+func AssetInventory(levels []CrawledLevel) []AssetCount {
+	counts := make(map[Asset]int)
+	seenPages := make(map[string]struct{})
+	for _, lvl := range levels {
+		for _, page := range lvl.CrawledPages {
+			if _, ok := seenPages[page.FinalUrl]; ok || page.IsFailed() {
+				continue
+			}
+			seenPages[page.FinalUrl] = struct{}{}
+			for _, asset := range page.Assets {
+				counts[asset]++
+			}
+		}
+	}
+
+	inventory := make([]AssetCount, 0, len(counts))
+	for asset, count := range counts {
+		inventory = append(inventory, AssetCount{Asset: asset, PagesNum: count})
+	}
+	sort.Slice(inventory, func(i, j int) bool {
+		if inventory[i].PagesNum != inventory[j].PagesNum {
+			return inventory[i].PagesNum > inventory[j].PagesNum
+		}
+		if inventory[i].Type != inventory[j].Type {
+			return inventory[i].Type < inventory[j].Type
+		}
+		return inventory[i].Url < inventory[j].Url
+	})
+
+	return inventory
+}
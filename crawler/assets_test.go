@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		srcset   string
+		expected []string
+	}{
+		{"a.png", []string{"a.png"}},
+		{"a.png 1x, b.png 2x", []string{"a.png", "b.png"}},
+		{" a.png 480w,b.png 800w ,", []string{"a.png", "b.png"}},
+		{"a.png, b.png 2x", []string{"a.png", "b.png"}},
+		{"/c/w_100,h_100/a.png 1x, /c/w_200,h_200/a.png 2x", []string{"/c/w_100,h_100/a.png", "/c/w_200,h_200/a.png"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if links := parseSrcset(tt.srcset); !reflect.DeepEqual(links, tt.expected) {
+			t.Errorf("parseSrcset(%q) = %q, expected %q", tt.srcset, links, tt.expected)
+		}
+	}
+}
+
+func TestAssetsExtractor(t *testing.T) {
+	pageUrl, _ := url.Parse("https://domain.com/one/")
+	html := `<html><head>
+	<link rel="stylesheet" href="/main.css">
+	<link rel="preload" as="font" href="/font.woff2" crossorigin>
+	<link rel="preload" href="/other.ttf">
+	<link rel="icon" href="/favicon.ico">
+	<link rel="canonical" href="/one/">
+	<script src="app.js"></script>
+	<script>inline()</script>
+</head><body>
+	<img src="a.png" srcset="a.png 1x, a@2x.png 2x">
+	<img data-src="/lazy.png" src="data:image/gif;base64,R0lGOD">
+	<picture><source srcset="/b.webp" type="image/webp"><img src="/b.jpg"></picture>
+	<video src="/v.mp4" poster="/poster.jpg"><source src="/v.webm"></video>
+	<audio><source src="/a.mp3"></audio>
+	<iframe src="https://www.youtube.com/embed/1"></iframe>
+	<object data="/flash.swf"></object>
+	<a href="/docs/Price.PDF?v=1">Price</a>
+	<a href="/page/">Page</a>
+	<img src="a.png">
+</body></html>`
+
+	page := newTestPage()
+	if err := extractPage(strings.NewReader(html), &Document{Url: pageUrl}, DefaultExtractors(), &page); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Asset{
+		{"https://domain.com/main.css", ASSET_STYLESHEET},
+		{"https://domain.com/font.woff2", ASSET_FONT},
+		{"https://domain.com/other.ttf", ASSET_FONT},
+		{"https://domain.com/favicon.ico", ASSET_IMAGE},
+		{"https://domain.com/one/app.js", ASSET_SCRIPT},
+		{"https://domain.com/one/a.png", ASSET_IMAGE},
+		{"https://domain.com/one/a@2x.png", ASSET_IMAGE},
+		{"https://domain.com/lazy.png", ASSET_IMAGE},
+		{"https://domain.com/b.webp", ASSET_IMAGE},
+		{"https://domain.com/b.jpg", ASSET_IMAGE},
+		{"https://domain.com/v.mp4", ASSET_VIDEO},
+		{"https://domain.com/poster.jpg", ASSET_IMAGE},
+		{"https://domain.com/v.webm", ASSET_VIDEO},
+		{"https://domain.com/a.mp3", ASSET_AUDIO},
+		{"https://www.youtube.com/embed/1", ASSET_IFRAME},
+		{"https://domain.com/flash.swf", ASSET_EMBED},
+		{"https://domain.com/docs/Price.PDF?v=1", ASSET_DOCUMENT},
+	}
+	if !reflect.DeepEqual(page.Assets, expected) {
+		t.Errorf("Assets = %+v\nexpected %+v", page.Assets, expected)
+	}
+}
+
+func TestAssetInventory(t *testing.T) {
+	css := Asset{"https://domain.com/main.css", ASSET_STYLESHEET}
+	logo := Asset{"https://domain.com/logo.png", ASSET_IMAGE}
+	js := Asset{"https://domain.com/app.js", ASSET_SCRIPT}
+
+	levels := []CrawledLevel{
+		{LevelNum: 0, CrawledPages: []CrawledPage{
+			{FinalUrl: "https://domain.com/", Assets: []Asset{css, logo}},
+		}},
+		{LevelNum: 1, CrawledPages: []CrawledPage{
+			{FinalUrl: "https://domain.com/a/", Assets: []Asset{js, css}},
+			{FinalUrl: "https://domain.com/", Assets: []Asset{css, logo}}, // redirected to the same page
+			{FinalUrl: "https://domain.com/404/", ErrorKind: ERR_KIND_STATUS, Assets: []Asset{js}},
+		}},
+	}
+
+	expected := []AssetCount{{css, 2}, {logo, 1}, {js, 1}}
+	if inventory := AssetInventory(levels); !reflect.DeepEqual(inventory, expected) {
+		t.Errorf("AssetInventory = %+v, expected %+v", inventory, expected)
+	}
+}
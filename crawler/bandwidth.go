@@ -0,0 +1,67 @@
+package crawler
+
+import "sort"
+
+const HEAVY_PAGES_NUM = 20 // heaviest pages in the bandwidth report
+
+// Content-Encoding of the not compressed pages in the report
+const ENCODING_IDENTITY = "identity"
+
+// Bytes of the crawled page
+type PageWeight struct {
+	Url          string `json:"url"`
+	TransferSize int64  `json:"transferSize"`
+	Size         int64  `json:"size"`
+	Encoding     string `json:"encoding"`
+}
+
+// Bytes transferred and decoded by the crawling
+type BandwidthReport struct {
+	PagesNum           int            `json:"pagesNum"`
+	TransferBytes      int64          `json:"transferBytes"`
+	DecodedBytes       int64          `json:"decodedBytes"`
+	CompressedPagesNum int            `json:"compressedPagesNum"`
+	Encodings          map[string]int `json:"encodings"`     // pages by Content-Encoding
+	HeaviestPages      []PageWeight   `json:"heaviestPages"` // by transferred bytes, HEAVY_PAGES_NUM at most
+}
+
+// Sums the bytes of all the requested pages including failed ones and resources
+func BuildBandwidthReport(levels []CrawledLevel) BandwidthReport {
+	report := BandwidthReport{Encodings: make(map[string]int)}
+	pages := make([]PageWeight, 0)
+	for _, lvl := range levels {
+		for _, page := range lvl.CrawledPages {
+			if page.StatusCode == 0 {
+				continue // no response
+			}
+			report.PagesNum++
+			report.TransferBytes += page.TransferSize
+			report.DecodedBytes += page.Size
+
+			encoding := page.Encoding
+			if encoding == "" {
+				encoding = ENCODING_IDENTITY
+			} else {
+				report.CompressedPagesNum++
+			}
+			report.Encodings[encoding]++
+
+			pages = append(pages, PageWeight{
+				Url:          page.Url,
+				TransferSize: page.TransferSize,
+				Size:         page.Size,
+				Encoding:     encoding,
+			})
+		}
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].TransferSize > pages[j].TransferSize
+	})
+	if len(pages) > HEAVY_PAGES_NUM {
+		pages = pages[:HEAVY_PAGES_NUM]
+	}
+	report.HeaviestPages = pages
+
+	return report
+}
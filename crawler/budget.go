@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Reasons of stopping the crawling before all the found links are crawled
+const (
+	STOP_REASON_MAX_DEPTH          = "maxDepth"
+	STOP_REASON_MAX_PAGES          = "maxPages"
+	STOP_REASON_MAX_PAGES_PER_HOST = "maxPagesPerHost"
+	STOP_REASON_MAX_DURATION       = "maxDuration"
+	STOP_REASON_MAX_BYTES          = "maxBytes"
+)
+
+// Limits of a single crawling, 0 - unlimited
+type Budget struct {
+	MaxDepth        int           // the deepest level number to crawl
+	MaxPages        int           // pages to crawl in total
+	MaxPagesPerHost int           // pages to crawl from a single host
+	MaxDuration     time.Duration // wall-clock time of the crawling
+	MaxBytes        int64         // response body bytes to transfer in total, compressed ones if encoded
+}
+
+// Spending of the budget during the crawling
+type budgetTracker struct {
+	budget       Budget
+	pages        int
+	pagesPerHost map[string]int
+	bytes        int64
+	stopReason   string // the reason to not crawl anything more
+}
+
+func newBudgetTracker(budget Budget) *budgetTracker {
+	return &budgetTracker{budget: budget, pagesPerHost: make(map[string]int)}
+}
+
+// Checks if the links found at depth may be crawled
+func (b *budgetTracker) allowsDepth(depth int) bool {
+	return b.budget.MaxDepth <= 0 || depth <= b.budget.MaxDepth
+}
+
+// Spends the budget on the url before it's crawled.
+// Returns false and the reason if the url must not be crawled
+func (b *budgetTracker) take(link string) (ok bool, reason string) {
+	if b.stopReason != "" {
+		return false, b.stopReason
+	}
+	if b.budget.MaxPages > 0 && b.pages >= b.budget.MaxPages {
+		b.stopReason = STOP_REASON_MAX_PAGES
+		return false, b.stopReason
+	}
+	if b.budget.MaxBytes > 0 && b.bytes >= b.budget.MaxBytes {
+		b.stopReason = STOP_REASON_MAX_BYTES
+		return false, b.stopReason
+	}
+
+	host := ""
+	if u, err := url.Parse(link); err == nil {
+		host = u.Host
+	}
+	if b.budget.MaxPagesPerHost > 0 && b.pagesPerHost[host] >= b.budget.MaxPagesPerHost {
+		return false, STOP_REASON_MAX_PAGES_PER_HOST
+	}
+
+	b.pages++
+	b.pagesPerHost[host]++
+	return true, ""
+}
+
+// Spends the budget on the crawled page
+func (b *budgetTracker) spend(page CrawledPage) {
+	b.bytes += page.TransferSize
+}
+
+// Stops the crawling because of the reason
+func (b *budgetTracker) stop(reason string) {
+	if b.stopReason == "" {
+		b.stopReason = reason
+	}
+}
+
+// Returns unique stop reasons of all the levels
+func StopReasons(levels []CrawledLevel) []string {
+	reasonsMap := make(map[string]struct{})
+	for _, lvl := range levels {
+		for _, reason := range lvl.StopReasons {
+			reasonsMap[reason] = struct{}{}
+		}
+	}
+
+	reasons := make([]string, 0, len(reasonsMap))
+	for reason := range reasonsMap {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	return reasons
+}
@@ -0,0 +1,144 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetTracker(t *testing.T) {
+	b := newBudgetTracker(Budget{MaxPages: 3, MaxPagesPerHost: 2, MaxDepth: 1})
+
+	if !b.allowsDepth(1) || b.allowsDepth(2) {
+		t.Errorf("depth 1 must be allowed, depth 2 must not")
+	}
+	for _, link := range []string{"http://a.com/1", "http://a.com/2", "http://b.com/1"} {
+		if ok, reason := b.take(link); !ok {
+			t.Errorf("%s is not taken: %s", link, reason)
+		}
+	}
+	if ok, reason := b.take("http://a.com/3"); ok || reason != STOP_REASON_MAX_PAGES {
+		t.Errorf("page over the limit: %v, %q", ok, reason)
+	}
+
+	b = newBudgetTracker(Budget{MaxPagesPerHost: 1})
+	b.take("http://a.com/1")
+	if ok, reason := b.take("http://a.com/2"); ok || reason != STOP_REASON_MAX_PAGES_PER_HOST {
+		t.Errorf("host page over the limit: %v, %q", ok, reason)
+	}
+	if ok, _ := b.take("http://b.com/1"); !ok || b.stopReason != "" {
+		t.Errorf("host limit stops the crawling")
+	}
+
+	b = newBudgetTracker(Budget{MaxBytes: 100})
+	b.take("http://a.com/1")
+	b.spend(CrawledPage{TransferSize: 100})
+	if ok, reason := b.take("http://a.com/2"); ok || reason != STOP_REASON_MAX_BYTES {
+		t.Errorf("page over the bytes: %v, %q", ok, reason)
+	}
+
+	b = newBudgetTracker(Budget{})
+	b.stop(STOP_REASON_MAX_DURATION)
+	b.stop(STOP_REASON_MAX_PAGES)
+	if ok, reason := b.take("http://a.com/1"); ok || reason != STOP_REASON_MAX_DURATION {
+		t.Errorf("page after the stop: %v, %q", ok, reason)
+	}
+}
+
+func TestStopReasons(t *testing.T) {
+	levels := []CrawledLevel{
+		{StopReasons: []string{STOP_REASON_MAX_PAGES, STOP_REASON_MAX_DEPTH}},
+		{},
+		{StopReasons: []string{STOP_REASON_MAX_PAGES}},
+	}
+	if reasons := strings.Join(StopReasons(levels), ","); reasons != "maxDepth,maxPages" {
+		t.Errorf("stop reasons = %q", reasons)
+	}
+}
+
+// Crawls TEST_SITE_URL with the budget
+func crawlWithBudget(t *testing.T, budget Budget, links ...string) []CrawledLevel {
+	srv := testSite(t, make(chan struct{}), nil)
+	c := testCrawler(t, srv)
+	c.Budget = budget
+	if len(links) == 0 {
+		links = []string{TEST_SITE_URL + "/"}
+	}
+
+	levels, err := c.Crawl(context.Background(), links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return levels
+}
+
+func crawledPagesNum(levels []CrawledLevel) (num int) {
+	for _, level := range levels {
+		num += len(level.CrawledPages)
+	}
+	return num
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	levels := crawlWithBudget(t, Budget{MaxPages: 3})
+
+	if num := crawledPagesNum(levels); num != 3 {
+		t.Errorf("crawled pages = %d, expected 3", num)
+	}
+	if reasons := StopReasons(levels); len(reasons) != 1 || reasons[0] != STOP_REASON_MAX_PAGES {
+		t.Errorf("stop reasons = %v", reasons)
+	}
+	if last := levels[len(levels)-1]; !last.Partial {
+		t.Errorf("level %d with not crawled pages isn't partial", last.LevelNum)
+	}
+}
+
+func TestCrawlMaxDepth(t *testing.T) {
+	levels := crawlWithBudget(t, Budget{MaxDepth: 1})
+
+	if len(levels) != 2 || levelPaths(levels[1]) != "/a /b" {
+		t.Fatalf("levels = %+v", levels)
+	}
+	if levels[1].Partial || strings.Join(levels[1].StopReasons, ",") != STOP_REASON_MAX_DEPTH {
+		t.Errorf("level 1: partial %v, stop reasons %v", levels[1].Partial, levels[1].StopReasons)
+	}
+}
+
+func TestCrawlMaxPagesPerHost(t *testing.T) {
+	levels := crawlWithBudget(t, Budget{MaxPagesPerHost: 2})
+
+	if num := crawledPagesNum(levels); num != 2 {
+		t.Errorf("crawled pages = %d, expected 2", num)
+	}
+	if reasons := StopReasons(levels); len(reasons) != 1 || reasons[0] != STOP_REASON_MAX_PAGES_PER_HOST {
+		t.Errorf("stop reasons = %v", reasons)
+	}
+}
+
+func TestCrawlMaxBytes(t *testing.T) {
+	levels := crawlWithBudget(t, Budget{MaxBytes: 1})
+
+	if num := crawledPagesNum(levels); num != 1 {
+		t.Errorf("crawled pages = %d, expected 1", num)
+	}
+	if reasons := StopReasons(levels); len(reasons) != 1 || reasons[0] != STOP_REASON_MAX_BYTES {
+		t.Errorf("stop reasons = %v", reasons)
+	}
+}
+
+func TestCrawlMaxDuration(t *testing.T) {
+	start := time.Now()
+	levels := crawlWithBudget(t, Budget{MaxDuration: 200 * time.Millisecond},
+		TEST_SITE_URL+"/a2", TEST_SITE_URL+"/slow")
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("crawling took %v", elapsed)
+	}
+	if len(levels) != 1 || levelPaths(levels[0]) != "/a2" || !levels[0].Partial {
+		t.Fatalf("levels = %+v", levels)
+	}
+	if reasons := StopReasons(levels); len(reasons) != 1 || reasons[0] != STOP_REASON_MAX_DURATION {
+		t.Errorf("stop reasons = %v", reasons)
+	}
+}
@@ -0,0 +1,36 @@
+package crawler
+
+import (
+	"bytes"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+	"io"
+	"unicode/utf8"
+)
+
+const CHARSET_SNIFF_LEN = 1024 // body bytes prescanned for <meta charset>, see charset.DetermineEncoding
+
+// Encoding of the pages without BOM, charset in Content-Type and <meta charset>
+const DEFAULT_CHARSET = "utf-8"
+
+var UTF8_BOM = []byte("\xef\xbb\xbf")
+
+// Returns the page encoding and its name by BOM, Content-Type charset and <meta charset> of the first body bytes.
+// Undeclared pages are UTF-8, unless they are not valid UTF-8
+func detectCharset(contentType string, head []byte) (encoding.Encoding, string) {
+	e, name, certain := charset.DetermineEncoding(head, contentType)
+	// windows-1252 is the fallback of DetermineEncoding for not declared encoding
+	if !certain && name == "windows-1252" && utf8.Valid(head) && !bytes.Contains(bytes.ToLower(head), []byte("charset")) {
+		return encoding.Nop, DEFAULT_CHARSET
+	}
+	return e, name
+}
+
+// Returns the reader of the body transcoded to UTF-8 from the named encoding
+func utf8Reader(r io.Reader, e encoding.Encoding, name string) io.Reader {
+	if name == DEFAULT_CHARSET {
+		return r
+	}
+	return transform.NewReader(r, e.NewDecoder())
+}
@@ -0,0 +1,69 @@
+package crawler
+
+import (
+	"context"
+	"golang.org/x/text/encoding/charmap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCharsets(t *testing.T) {
+	const title = "Главная страница"
+	encode := func(e *charmap.Charmap, s string) string {
+		encoded, err := e.NewEncoder().String(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+	page := func(meta string, title string) string {
+		return "<html><head>" + meta + "<title>" + title + "</title></head><body><h1>" + title + "</h1></body></html>"
+	}
+
+	bodies := map[string]string{
+		"/header-1251": page("", encode(charmap.Windows1251, title)),
+		"/meta-koi8":   page(`<meta charset="koi8-r">`, encode(charmap.KOI8R, title)),
+		"/http-equiv": page(`<meta http-equiv="Content-Type" content="text/html; charset=windows-1251">`,
+			encode(charmap.Windows1251, title)),
+		"/bom":  "\xef\xbb\xbf" + page("", title),
+		"/utf8": page("", title),
+		// Nothing but ascii in the prescanned bytes
+		"/long-head": page("<script>"+strings.Repeat("var a = 1;\n", 200)+"</script>", title),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := "text/html"
+		if r.URL.Path == "/header-1251" {
+			contentType = "text/html; charset=windows-1251"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(bodies[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path    string
+		charset string
+	}{
+		{"/header-1251", "windows-1251"},
+		{"/meta-koi8", "koi8-r"},
+		{"/http-equiv", "windows-1251"},
+		{"/bom", "utf-8"},
+		{"/utf8", "utf-8"},
+		{"/long-head", "utf-8"},
+	}
+
+	c := &Crawler{}
+	for _, test := range tests {
+		page, err := c.ParsePage(context.Background(), srv.URL+test.path)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+		if page.Charset != test.charset || page.Title != title || page.H1 != title {
+			t.Errorf("%s: charset %q, title %q, h1 %q, expected %q, %q", test.path, page.Charset, page.Title,
+				page.H1, test.charset, title)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Conditions the ChromeRenderer waits for before taking the page DOM,
+// any other value of ChromeConfig.WaitFor is a css selector of the element to wait for
+const (
+	RENDER_WAIT_LOAD         = "load"        // load event of the page
+	RENDER_WAIT_NETWORK_IDLE = "networkIdle" // no network activity for 500ms
+)
+
+const (
+	DEFAULT_RENDER_TIMEOUT  = 30 * time.Second
+	DEFAULT_RENDER_MAX_TABS = 4
+)
+
+// Settings of the headless Chrome rendering
+type ChromeConfig struct {
+	ExecPath  string        // Chrome binary, looked up in the PATH if empty
+	RemoteUrl string        // DevTools websocket url of running Chrome, no browser is started if set
+	UserAgent string        // DEFAULT_USER_AGENT if empty
+	WaitFor   string        // RENDER_WAIT_LOAD if empty, RENDER_WAIT_NETWORK_IDLE or css selector
+	WaitDelay time.Duration // extra time for the scripts after the wait condition is met
+	Timeout   time.Duration // rendering of a single page, DEFAULT_RENDER_TIMEOUT if not positive
+	MaxTabs   int           // pages rendered at once, DEFAULT_RENDER_MAX_TABS if not positive
+	// Requests of the pages like the ones of the Fetcher, see WithFetcherConfig.
+	// Proxy and certificates settings are applied to the started browser only
+	Headers            map[string]string // extra headers sent with every request
+	Cookies            []*http.Cookie    // cookies of the rendered pages
+	ProxyUrl           string            // http(s)://host:port, environment proxy if empty
+	InsecureSkipVerify bool              // do not verify server certificates
+}
+
+func DefaultChromeConfig() ChromeConfig {
+	return ChromeConfig{
+		UserAgent: DEFAULT_USER_AGENT,
+		WaitFor:   RENDER_WAIT_LOAD,
+		Timeout:   DEFAULT_RENDER_TIMEOUT,
+		MaxTabs:   DEFAULT_RENDER_MAX_TABS,
+	}
+}
+
+// Returns the config with User-Agent, headers, cookies, proxy and certificates checking of the Fetcher,
+// so rendered pages are requested as the fetched ones
+func (c ChromeConfig) WithFetcherConfig(fetcherConfig FetcherConfig) ChromeConfig {
+	c.UserAgent = fetcherConfig.UserAgent
+	c.Headers = fetcherConfig.Headers
+	c.Cookies = fetcherConfig.Cookies
+	c.ProxyUrl = fetcherConfig.ProxyUrl
+	c.InsecureSkipVerify = fetcherConfig.InsecureSkipVerify
+	return c
+}
+
+// Command line flags of the started browser
+func chromeFlags(config ChromeConfig) (map[string]any, error) {
+	flags := map[string]any{"user-agent": config.UserAgent}
+	if config.ProxyUrl != "" {
+		proxyUrl, err := url.Parse(config.ProxyUrl)
+		if err != nil || proxyUrl.Host == "" {
+			return nil, errors.New("Failed to parse proxy url: \"" + config.ProxyUrl + "\"")
+		}
+		// Chrome asks for the proxy credentials interactively
+		if proxyUrl.User != nil {
+			return nil, errors.New("Proxy credentials are not supported by chrome: \"" + proxyUrl.Redacted() + "\"")
+		}
+		flags["proxy-server"] = proxyUrl.Scheme + "://" + proxyUrl.Host
+	}
+	if config.InsecureSkipVerify {
+		flags["ignore-certificate-errors"] = true
+	}
+	return flags, nil
+}
+
+// Renders the pages in the tabs of headless Chrome driven over the DevTools protocol.
+// Safe for concurrent use, Close stops the browser
+type ChromeRenderer struct {
+	config     ChromeConfig
+	browserCtx context.Context
+	cancel     context.CancelFunc
+	tabs       chan struct{} // slots of the open tabs
+}
+
+// Starts the browser or connects to the running one
+func NewChromeRenderer(config ChromeConfig) (*ChromeRenderer, error) {
+	if config.UserAgent == "" {
+		config.UserAgent = DEFAULT_USER_AGENT
+	}
+	if config.WaitFor == "" {
+		config.WaitFor = RENDER_WAIT_LOAD
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DEFAULT_RENDER_TIMEOUT
+	}
+	if config.MaxTabs <= 0 {
+		config.MaxTabs = DEFAULT_RENDER_MAX_TABS
+	}
+
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if config.RemoteUrl != "" {
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(context.Background(), config.RemoteUrl)
+	} else {
+		flags, err := chromeFlags(config)
+		if err != nil {
+			return nil, err
+		}
+		opts := chromedp.DefaultExecAllocatorOptions[:]
+		for name, value := range flags {
+			opts = append(opts, chromedp.Flag(name, value))
+		}
+		if config.ExecPath != "" {
+			opts = append(opts, chromedp.ExecPath(config.ExecPath))
+		}
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(context.Background(), opts...)
+	}
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		return nil, errors.New("Failed to start chrome with error: \"" + err.Error() + "\"")
+	}
+
+	return &ChromeRenderer{
+		config:     config,
+		browserCtx: browserCtx,
+		cancel:     cancel,
+		tabs:       make(chan struct{}, config.MaxTabs),
+	}, nil
+}
+
+func (r *ChromeRenderer) Config() ChromeConfig {
+	return r.config
+}
+
+// Closes the tabs and stops the started browser
+func (r *ChromeRenderer) Close() {
+	r.cancel()
+}
+
+// Opens the link in a new tab and returns the DOM after the wait condition is met as UTF-8 html.
+// Status and headers are the ones of the page document, redirects are recorded as by the Fetcher
+func (r *ChromeRenderer) Render(ctx context.Context, link string) (*http.Response, error) {
+	select {
+	case r.tabs <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-r.tabs }()
+
+	tabCtx, cancelTab := chromedp.NewContext(r.browserCtx)
+	defer cancelTab()
+	stop := context.AfterFunc(ctx, cancelTab)
+	defer stop()
+	tabCtx, cancelTimeout := context.WithTimeout(tabCtx, r.config.Timeout)
+	defer cancelTimeout()
+
+	networkIdle := r.listen(tabCtx, redirectRecorderFrom(ctx))
+
+	requestActions, err := r.requestActions(link)
+	if err != nil {
+		return nil, err
+	}
+	docResp, err := chromedp.RunResponse(tabCtx, append(requestActions, chromedp.Navigate(link))...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if docResp == nil {
+		return nil, errors.New("No document response of: \"" + link + "\"")
+	}
+
+	// Failed pages are not parsed, there is nothing to wait for
+	actions := make([]chromedp.Action, 0)
+	if docResp.Status == http.StatusOK {
+		switch r.config.WaitFor {
+		case RENDER_WAIT_LOAD:
+		case RENDER_WAIT_NETWORK_IDLE:
+			actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+				select {
+				case <-networkIdle:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}))
+		default:
+			actions = append(actions, chromedp.WaitReady(r.config.WaitFor, chromedp.ByQuery))
+		}
+		if r.config.WaitDelay > 0 {
+			actions = append(actions, chromedp.Sleep(r.config.WaitDelay))
+		}
+	}
+	var html string
+	actions = append(actions, chromedp.Evaluate("document.documentElement ? document.documentElement.outerHTML : ''",
+		&html))
+	if err = chromedp.Run(tabCtx, actions...); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	return renderedResponse(ctx, docResp, html)
+}
+
+// Sets User-Agent, headers and cookies of the tab before the link is opened
+func (r *ChromeRenderer) requestActions(link string) ([]chromedp.Action, error) {
+	actions := []chromedp.Action{emulation.SetUserAgentOverride(r.config.UserAgent)}
+	if len(r.config.Headers) > 0 {
+		headers := make(network.Headers, len(r.config.Headers))
+		for name, value := range r.config.Headers {
+			headers[name] = value
+		}
+		actions = append(actions, network.SetExtraHTTPHeaders(headers))
+	}
+	if len(r.config.Cookies) > 0 {
+		u, err := url.Parse(link)
+		if err != nil {
+			return nil, err
+		}
+		// Cookies without domain are sent to the host of the link as the Fetcher does
+		cookies := make([]*network.CookieParam, 0, len(r.config.Cookies))
+		for _, cookie := range r.config.Cookies {
+			domain := cookie.Domain
+			if domain == "" {
+				domain = u.Hostname()
+			}
+			path := cookie.Path
+			if path == "" {
+				path = "/"
+			}
+			cookies = append(cookies, &network.CookieParam{Name: cookie.Name, Value: cookie.Value,
+				Domain: domain, Path: path})
+		}
+		actions = append(actions, network.SetCookies(cookies))
+	}
+	return actions, nil
+}
+
+// Records redirects of the page document and returns the channel closed when its network is idle
+func (r *ChromeRenderer) listen(tabCtx context.Context, recorder *redirectRecorder) <-chan struct{} {
+	networkIdle := make(chan struct{})
+	var once sync.Once
+	var loaderId string // of the page document, the first one loaded in the tab
+
+	chromedp.ListenTarget(tabCtx, func(ev any) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if ev.Type != network.ResourceTypeDocument || loaderId != "" && string(ev.LoaderID) != loaderId {
+				return
+			}
+			loaderId = string(ev.LoaderID)
+			if ev.RedirectResponse != nil && recorder != nil {
+				recorder.add(RedirectHop{
+					Url:        ev.RedirectResponse.URL,
+					StatusCode: int(ev.RedirectResponse.Status),
+					Location:   ev.Request.URL,
+				})
+			}
+		case *page.EventLifecycleEvent:
+			if ev.Name == "networkIdle" && loaderId != "" && string(ev.LoaderID) == loaderId {
+				once.Do(func() { close(networkIdle) })
+			}
+		}
+	})
+
+	return networkIdle
+}
+
+// Response of the page document with the rendered html body
+func renderedResponse(ctx context.Context, docResp *network.Response, html string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, docResp.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	for name, value := range docResp.Headers {
+		if values, ok := value.(string); ok {
+			for _, v := range strings.Split(values, "\n") {
+				header.Add(name, v)
+			}
+		}
+	}
+	// The body is the serialized DOM
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	header.Set("Content-Type", docResp.MimeType+"; charset=utf-8")
+
+	statusCode := int(docResp.Status)
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(html)),
+		ContentLength: int64(len(html)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Content codings the Fetcher asks for and decodes
+const ACCEPT_ENCODING = "gzip, deflate, br"
+
+// Bytes read from the bodies which are not parsed(error pages, resources), lets the connection be reused
+const DRAIN_BODY_SIZE = 64 * 1024
+
+// Response body decoded according to its Content-Encoding,
+// counts the bytes transferred over the network
+type decodedBody struct {
+	raw      *countingReader
+	closer   io.Closer
+	encoding string    // Content-Encoding, empty if not compressed
+	decoder  io.Reader // created at the first Read
+	err      error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.decoder == nil && b.err == nil {
+		b.decoder, b.err = newDecoder(b.encoding, b.raw)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.decoder.Read(p)
+}
+
+func (b *decodedBody) Close() error {
+	return b.closer.Close()
+}
+
+func isSupportedEncoding(contentEncoding string) bool {
+	switch contentEncoding {
+	case "gzip", "x-gzip", "deflate", "br":
+		return true
+	}
+	return false
+}
+
+func newDecoder(contentEncoding string, r io.Reader) (io.Reader, error) {
+	switch contentEncoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "br":
+		return brotli.NewReader(r), nil
+	case "deflate":
+		// zlib stream as the spec says or raw deflate sent by some servers
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	}
+	return r, nil
+}
+
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// Replaces the response body with the decoded one. Like the transparent gzip of http.Transport,
+// Content-Encoding and Content-Length of the decoded response are removed
+func decodeBody(resp *http.Response) {
+	contentEncoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if !isSupportedEncoding(contentEncoding) {
+		contentEncoding = ""
+	}
+	resp.Body = &decodedBody{raw: &countingReader{Reader: resp.Body}, closer: resp.Body, encoding: contentEncoding}
+
+	if contentEncoding != "" && resp.Request.Method != http.MethodHead {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+}
+
+// Reads the rest of the body which is not parsed, no more than DRAIN_BODY_SIZE bytes.
+// Returns the bytes transferred, see transferInfo
+func drainBody(resp *http.Response, body io.Reader) int64 {
+	_, _ = io.CopyN(io.Discard, body, DRAIN_BODY_SIZE)
+	transferred, _ := transferInfo(resp)
+	return transferred
+}
+
+// Returns the bytes transferred and the Content-Encoding of the response got by the Fetcher
+func transferInfo(resp *http.Response) (transferred int64, contentEncoding string) {
+	body := resp.Body
+	if released, ok := body.(*releasingBody); ok {
+		body = released.ReadCloser
+	}
+	if body, ok := body.(*decodedBody); ok {
+		return body.raw.Count, body.encoding
+	}
+	return 0, ""
+}
@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressedPages(t *testing.T) {
+	page := "<html><head><title>Compressed</title></head><body>" + strings.Repeat("<p>text</p>", 1000) +
+		"</body></html>"
+	compress := func(newWriter func(w io.Writer) io.WriteCloser) []byte {
+		buf := &bytes.Buffer{}
+		w := newWriter(buf)
+		w.Write([]byte(page))
+		w.Close()
+		return buf.Bytes()
+	}
+
+	bodies := map[string][]byte{
+		"gzip":     compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }),
+		"br":       compress(func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }),
+		"deflate":  compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }),
+		"identity": []byte(page),
+	}
+	rawDeflate := compress(func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != ACCEPT_ENCODING {
+			http.Error(w, "unexpected Accept-Encoding: "+r.Header.Get("Accept-Encoding"), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		encoding := strings.TrimPrefix(r.URL.Path, "/")
+		body := bodies[encoding]
+		if encoding == "raw-deflate" {
+			encoding, body = "deflate", rawDeflate
+		}
+		if encoding != "identity" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		encoding string
+		transfer int
+	}{
+		{"/gzip", "gzip", len(bodies["gzip"])},
+		{"/br", "br", len(bodies["br"])},
+		{"/deflate", "deflate", len(bodies["deflate"])},
+		{"/raw-deflate", "deflate", len(rawDeflate)},
+		{"/identity", "", len(page)},
+	}
+
+	c := &Crawler{}
+	pages := make([]CrawledPage, 0)
+	for _, test := range tests {
+		crawled, err := c.ParsePage(context.Background(), srv.URL+test.path)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+		pages = append(pages, crawled)
+		if crawled.Title != "Compressed" || crawled.Encoding != test.encoding {
+			t.Errorf("%s: title %q, encoding %q", test.path, crawled.Title, crawled.Encoding)
+		}
+		if crawled.Size != int64(len(page)) || crawled.TransferSize != int64(test.transfer) {
+			t.Errorf("%s: size %d, transfer size %d, expected %d, %d", test.path, crawled.Size,
+				crawled.TransferSize, len(page), test.transfer)
+		}
+	}
+
+	report := BuildBandwidthReport([]CrawledLevel{{CrawledPages: pages}})
+	if report.PagesNum != 5 || report.CompressedPagesNum != 4 || report.DecodedBytes != 5*int64(len(page)) {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Encodings["deflate"] != 2 || report.Encodings[ENCODING_IDENTITY] != 1 {
+		t.Errorf("encodings = %v", report.Encodings)
+	}
+	if len(report.HeaviestPages) != 5 || report.HeaviestPages[0].Encoding != ENCODING_IDENTITY {
+		t.Errorf("heaviest pages = %+v", report.HeaviestPages)
+	}
+}
@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Mime types of the pages parsed by default
+var DEFAULT_HTML_MIME_TYPES = []string{"text/html", "application/xhtml+xml"}
+
+const SNIFF_LEN = 512 // body bytes used to detect the content type, see http.DetectContentType
+
+// Mime types meaning the server doesn't know the content type
+var GENERIC_MIME_TYPES = []string{"", "application/octet-stream", "application/unknown", "unknown/unknown"}
+
+func (c *Crawler) allowedMimeTypes() []string {
+	if len(c.AllowedMimeTypes) == 0 {
+		return DEFAULT_HTML_MIME_TYPES
+	}
+	return c.AllowedMimeTypes
+}
+
+func (c *Crawler) maxBodySize() int64 {
+	if c.MaxBodySize <= 0 {
+		return MAX_PAGE_SIZE
+	}
+	return c.MaxBodySize
+}
+
+// Checks if the page of the mime type should be parsed. Allowed types may be "type/*" wildcards
+func (c *Crawler) isAllowedMimeType(mimeType string) bool {
+	for _, allowed := range c.allowedMimeTypes() {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "" {
+			continue
+		}
+		if allowed == mimeType ||
+			strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns lowercased media type of the Content-Type header without parameters, empty if it's broken
+func mediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// Returns the mime type of the response by its Content-Type header and the first body bytes.
+// Sniffed type is used if the header is missing or generic, or if the body is binary while the header claims text
+func detectMimeType(resp *http.Response, body *bufio.Reader) string {
+	declared := mediaType(resp.Header.Get("Content-Type"))
+
+	head, _ := body.Peek(SNIFF_LEN)
+	if len(head) == 0 {
+		return declared
+	}
+	sniffed := mediaType(http.DetectContentType(head))
+	if sniffed == "text/xml" && bytes.Contains(bytes.ToLower(head), []byte("<html")) {
+		sniffed = "application/xhtml+xml"
+	}
+
+	if contains(GENERIC_MIME_TYPES, declared) {
+		return sniffed
+	}
+	isText := strings.HasPrefix(declared, "text/") || strings.HasSuffix(declared, "+xml")
+	if isText && !strings.HasPrefix(sniffed, "text/") && sniffed != "application/octet-stream" &&
+		sniffed != "application/xhtml+xml" {
+		return sniffed // mislabeled pdf, image, archive...
+	}
+	return declared
+}
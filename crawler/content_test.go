@@ -0,0 +1,119 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestContentTypes(t *testing.T) {
+	const page = `<html><head><title>Page</title></head><body><a href="/next">next</a></body></html>`
+	pdf := "%PDF-1.4\n" + strings.Repeat("binary", 1000)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(page))
+		case "/xhtml":
+			w.Header().Set("Content-Type", "application/xhtml+xml")
+			w.Write([]byte(`<?xml version="1.0"?>` + page))
+		case "/no-type":
+			w.Header()["Content-Type"] = nil // do not let the server sniff
+			w.Write([]byte(page))
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"links": ["<a href='/x'>"]}`))
+		case "/pdf":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Length", strconv.Itoa(len(pdf)))
+			w.Write([]byte(pdf))
+		case "/mislabeled.pdf":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(pdf))
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		mimeType string
+		resource bool
+	}{
+		{"/html", "text/html", false},
+		{"/xhtml", "application/xhtml+xml", false},
+		{"/no-type", "text/html", false},
+		{"/json", "application/json", true},
+		{"/pdf", "application/pdf", true},
+		{"/mislabeled.pdf", "application/pdf", true},
+	}
+
+	c := &Crawler{}
+	for _, test := range tests {
+		page, err := c.ParsePage(context.Background(), srv.URL+test.path)
+		if err != nil {
+			t.Errorf("%s: %v", test.path, err)
+			continue
+		}
+		if page.MimeType != test.mimeType || page.Resource != test.resource {
+			t.Errorf("%s: mime type %q, resource %v, expected %q, %v",
+				test.path, page.MimeType, page.Resource, test.mimeType, test.resource)
+		}
+		if test.resource && (page.Title != "" || len(page.Links) != 0) {
+			t.Errorf("%s: resource is parsed: %q %v", test.path, page.Title, page.Links)
+		}
+		if !test.resource && (page.Title != "Page" || len(page.Links) != 1) {
+			t.Errorf("%s: page is not parsed: %q %v", test.path, page.Title, page.Links)
+		}
+	}
+
+	// Resource body isn't downloaded
+	resource, _ := c.ParsePage(context.Background(), srv.URL+"/pdf")
+	if resource.Size != int64(len(pdf)) {
+		t.Errorf("resource size = %d, expected Content-Length %d", resource.Size, len(pdf))
+	}
+
+	c = &Crawler{AllowedMimeTypes: []string{"text/html", "application/*"}, MaxBodySize: 10}
+	json, _ := c.ParsePage(context.Background(), srv.URL+"/json")
+	if json.Resource {
+		t.Errorf("application/* does not allow json")
+	}
+	html, _ := c.ParsePage(context.Background(), srv.URL+"/html")
+	if html.Size != 10 || html.Title != "" {
+		t.Errorf("body is read beyond MaxBodySize: size %d, title %q", html.Size, html.Title)
+	}
+}
+
+func TestTransferSizeNotParsed(t *testing.T) {
+	notFound := strings.Repeat("<p>not found</p>", 200)
+	image := "\x89PNG\r\n\x1a\n" + strings.Repeat("pixels", 500)
+	video := strings.Repeat("frames", 100*1024)
+
+	srv := newFixtureServer(t, map[string][]fixture{
+		"/missing":   {{status: http.StatusNotFound, body: notFound}},
+		"/image.png": {{header: map[string]string{"Content-Type": "image/png"}, body: image}},
+		"/video.mp4": {{header: map[string]string{"Content-Type": "video/mp4"}, body: video}},
+	})
+
+	c := &Crawler{}
+	missing, err := c.ParsePage(context.Background(), srv.URL+"/missing")
+	if err == nil || missing.TransferSize != int64(len(notFound)) {
+		t.Errorf("404 transfer size = %d, expected %d, err %v", missing.TransferSize, len(notFound), err)
+	}
+	png, _ := c.ParsePage(context.Background(), srv.URL+"/image.png")
+	if !png.Resource || png.TransferSize != int64(len(image)) {
+		t.Errorf("image transfer size = %d, expected %d", png.TransferSize, len(image))
+	}
+	mp4, _ := c.ParsePage(context.Background(), srv.URL+"/video.mp4")
+	if !mp4.Resource || mp4.TransferSize < DRAIN_BODY_SIZE || mp4.TransferSize >= int64(len(video)) {
+		t.Errorf("video transfer size = %d, expected about %d", mp4.TransferSize, DRAIN_BODY_SIZE)
+	}
+
+	report := BuildBandwidthReport([]CrawledLevel{{CrawledPages: []CrawledPage{missing, png}}})
+	if report.TransferBytes != int64(len(notFound)+len(image)) {
+		t.Errorf("transfer bytes = %d", report.TransferBytes)
+	}
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Kinds of sitemap coverage issues
+const (
+	COVERAGE_ORPHAN         = "orphan"        // in the sitemap, but not linked from crawled pages
+	COVERAGE_NOT_IN_SITEMAP = "notInSitemap"  // crawled, but missing from the sitemap
+	COVERAGE_NOT_CRAWLED    = "notCrawled"    // in the sitemap, but filtered out by the crawling rules
+	COVERAGE_NOT_200        = "not200"        // in the sitemap, but answers not 200 or fails
+	COVERAGE_REDIRECT       = "redirect"      // in the sitemap, but redirects
+	COVERAGE_NOINDEX        = "noindex"       // in the sitemap, but marked noindex
+	COVERAGE_CANONICALIZED  = "canonicalized" // in the sitemap, but points to another canonical url
+)
+
+type CoverageIssue struct {
+	Kind    string `json:"kind"`
+	Url     string `json:"url"`
+	Details string `json:"details,omitempty"` // status, error, redirect target or canonical url
+}
+
+// Comparison of the sitemap with the crawled pages
+type CoverageReport struct {
+	SitemapUrlsNum int             `json:"sitemapUrlsNum"`
+	CrawledUrlsNum int             `json:"crawledUrlsNum"`
+	IssuesNum      map[string]int  `json:"issuesNum"` // by kind
+	Issues         []CoverageIssue `json:"issues"`
+}
+
+// Builds the coverage report using the default normalizer
+func BuildCoverageReport(sitemapLinks []string, levels []CrawledLevel) CoverageReport {
+	return (&Crawler{}).BuildCoverageReport(sitemapLinks, levels)
+}
+
+// Compares sitemap links(see GetLinksFromSitemap) with the crawled pages(see ExtractUniqueLinks)
+func (c *Crawler) BuildCoverageReport(sitemapLinks []string, levels []CrawledLevel) CoverageReport {
+	report := CoverageReport{IssuesNum: make(map[string]int), Issues: make([]CoverageIssue, 0)}
+	addIssue := func(kind string, url string, details string) {
+		report.IssuesNum[kind]++
+		report.Issues = append(report.Issues, CoverageIssue{Kind: kind, Url: url, Details: details})
+	}
+
+	// Sitemap
+	sitemapMap := make(map[string]struct{}, len(sitemapLinks))
+	for _, link := range sitemapLinks {
+		sitemapMap[c.normalize(link)] = struct{}{}
+	}
+	sitemap := make([]string, 0, len(sitemapMap))
+	for link := range sitemapMap {
+		sitemap = append(sitemap, link)
+	}
+	sort.Strings(sitemap)
+	report.SitemapUrlsNum = len(sitemap)
+
+	// Crawled pages by requested url and links between them
+	pagesByUrl := make(map[string]CrawledPage)
+	linkedMap := make(map[string]struct{})
+	for _, lvl := range levels {
+		for _, page := range lvl.CrawledPages {
+			pagesByUrl[c.normalize(page.Url)] = page
+			for _, link := range page.Links {
+				link = c.normalize(link)
+				if link != page.Url && link != page.FinalUrl { // self links do not make page reachable
+					linkedMap[link] = struct{}{}
+				}
+			}
+		}
+	}
+	crawled := c.ExtractUniqueLinks(levels)
+	sort.Strings(crawled)
+	report.CrawledUrlsNum = len(crawled)
+
+	// Sitemap urls
+	for _, link := range sitemap {
+		if _, ok := linkedMap[link]; !ok {
+			addIssue(COVERAGE_ORPHAN, link, "")
+		}
+
+		page, ok := pagesByUrl[link]
+		if !ok {
+			addIssue(COVERAGE_NOT_CRAWLED, link, "")
+			continue
+		}
+		if page.IsFailed() {
+			details := page.Error
+			if page.StatusCode != 0 {
+				details = strconv.Itoa(page.StatusCode)
+			}
+			addIssue(COVERAGE_NOT_200, link, details)
+		}
+		if len(page.RedirectChain) > 0 {
+			addIssue(COVERAGE_REDIRECT, link, page.FinalUrl)
+		}
+		if page.IsFailed() {
+			continue
+		}
+		if page.NoIndex {
+			addIssue(COVERAGE_NOINDEX, link, "")
+		}
+		if page.CanonicalUrl != "" {
+			if canonical := c.normalize(page.CanonicalUrl); canonical != page.FinalUrl {
+				addIssue(COVERAGE_CANONICALIZED, link, canonical)
+			}
+		}
+	}
+
+	// Crawled urls
+	for _, link := range crawled {
+		if _, ok := sitemapMap[link]; !ok {
+			addIssue(COVERAGE_NOT_IN_SITEMAP, link, "")
+		}
+	}
+
+	return report
+}
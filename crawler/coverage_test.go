@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBuildCoverageReport(t *testing.T) {
+	const site = "http://example.com"
+	page := func(path string, links ...string) CrawledPage {
+		p := CrawledPage{Url: site + path, FinalUrl: site + path, StatusCode: 200}
+		for _, link := range links {
+			p.Links = append(p.Links, site+link)
+		}
+		return p
+	}
+
+	home := page("/", "/a", "/broken", "/moved", "/noindex", "/canon", "/extra", "/")
+	orphan := page("/orphan", "/orphan")
+	broken := page("/broken")
+	broken.StatusCode = 404
+	broken.ErrorKind = ERR_KIND_STATUS
+	moved := page("/moved")
+	moved.FinalUrl = site + "/a"
+	moved.RedirectChain = []RedirectHop{{Url: site + "/moved", StatusCode: 301, Location: site + "/a"}}
+	noindex := page("/noindex")
+	noindex.NoIndex = true
+	canon := page("/canon")
+	canon.CanonicalUrl = site + "/a?utm_source=x"
+	selfCanon := page("/a")
+	selfCanon.CanonicalUrl = site + "/a"
+
+	levels := []CrawledLevel{
+		{LevelNum: 0, CrawledPages: []CrawledPage{home, orphan}},
+		{LevelNum: 1, CrawledPages: []CrawledPage{selfCanon, broken, moved, noindex, canon, page("/extra")}},
+	}
+	sitemap := []string{site + "/a", site + "/a#top", site + "/orphan", site + "/missing", site + "/broken",
+		site + "/moved", site + "/noindex", site + "/canon"}
+
+	report := BuildCoverageReport(sitemap, levels)
+
+	if report.SitemapUrlsNum != 7 || report.CrawledUrlsNum != 6 {
+		t.Errorf("sitemap urls %d, crawled urls %d", report.SitemapUrlsNum, report.CrawledUrlsNum)
+	}
+
+	issues := make([]string, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		issues = append(issues, issue.Kind+" "+strings.TrimPrefix(issue.Url, site)+" "+
+			strings.TrimPrefix(issue.Details, site))
+	}
+	sort.Strings(issues)
+	expected := []string{
+		"canonicalized /canon /a",
+		"noindex /noindex ",
+		"not200 /broken 404",
+		"notCrawled /missing ",
+		"notInSitemap / ",
+		"notInSitemap /extra ",
+		"orphan /missing ",
+		"orphan /orphan ",
+		"redirect /moved /a",
+	}
+	if strings.Join(issues, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("issues:\n%s\nexpected:\n%s", strings.Join(issues, "\n"), strings.Join(expected, "\n"))
+	}
+	if report.IssuesNum[COVERAGE_ORPHAN] != 2 || report.IssuesNum[COVERAGE_NOT_IN_SITEMAP] != 2 {
+		t.Errorf("issues num = %v", report.IssuesNum)
+	}
+}
@@ -2,279 +2,506 @@ package crawler
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"errors"
-	"github.com/PuerkitoBio/goquery"
+	"go-crawler/normalizer"
 	"go-crawler/utils"
 	"go-crawler/validator"
+	"io"
 	"log"
-	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-const (
-	PARALLEL_LVL = 4
-)
+// Pagination url, the first group is the url of the first page
+var PAGINATION_REGEXP = regexp.MustCompile(`^((http|https):\/\/.*\/)(page|p)\/\d+\/?$`)
 
 type CrawledPage struct {
-	Url            string            `json:"url"`
+	Url            string            `json:"url"`                     // requested url
+	FinalUrl       string            `json:"finalUrl"`                // url after redirects
+	RedirectChain  []RedirectHop     `json:"redirectChain,omitempty"` // redirect hops starting with Url
+	StatusCode     int               `json:"statusCode"`              // 0 if no response
+	ErrorKind      string            `json:"errorKind,omitempty"`     // one of ERR_KIND_*
+	Error          string            `json:"error,omitempty"`
+	ContentType    string            `json:"contentType,omitempty"`
+	MimeType       string            `json:"mimeType,omitempty"` // by ContentType and the body sniffing
+	Resource       bool              `json:"resource,omitempty"` // not allowed mime type, the body isn't parsed
+	Charset        string            `json:"charset,omitempty"`  // encoding the page is transcoded to UTF-8 from
+	Size           int64             `json:"size"`               // response body bytes, Content-Length of resources
+	TransferSize   int64             `json:"transferSize"`       // body bytes received, compressed ones if encoded
+	Encoding       string            `json:"encoding,omitempty"` // Content-Encoding the body is decoded from
+	FetchTimeMs    int64             `json:"fetchTimeMs"`        // fetching and parsing time
 	H1             string            `json:"h1"`
 	Title          string            `json:"title"`
 	Links          []string          `json:"links"`
+	LinksInfo      []LinkInfo        `json:"linksInfo"` // <a href> links with their details
 	HreflangUrlMap map[string]string `json:"hreflangUrlMap"`
-	Imgs           []string          `json:"imgs"`
+	Imgs           []string          `json:"imgs"`   // raw <img src>
+	Assets         []Asset           `json:"assets"` // resolved urls of the resources, see AssetsExtractor
 	CanonicalUrl   string            `json:"canonicalUrl"`
-	NoIndex        bool              `json:"noIndex"`
+	ContentHash    string            `json:"contentHash,omitempty"`    // sha1 of the main text, see ContentExtractor
+	SimHash        uint64            `json:"simHash,string,omitempty"` // fingerprint of the main text
+	NoIndex        bool              `json:"noIndex"`                  // noindex for all the bots, see Seo.Robots
+	Seo            SeoMeta           `json:"seo"`
+	Attempts       int               `json:"attempts"`        // number of requests made to get the page
+	Extra          map[string]any    `json:"extra,omitempty"` // values of custom extractors by their names
 }
 
 func (cp CrawledPage) IsEmpty() bool {
 	if (cp.Url == "") && (cp.H1 == "") && (cp.Title == "") && (len(cp.Links) == 0) && (len(cp.HreflangUrlMap) == 0) &&
-		(len(cp.Imgs) == 0) && (cp.CanonicalUrl == "") && (cp.NoIndex == false) {
+		(len(cp.Imgs) == 0) && (cp.CanonicalUrl == "") && (cp.NoIndex == false) && (cp.Attempts == 0) {
 		return true
 	}
 	return false
 }
 
+// Page was not got or not parsed
+func (cp CrawledPage) IsFailed() bool {
+	return cp.ErrorKind != ""
+}
+
 type CrawledLevel struct {
 	LevelNum     int           `json:"levelNum"`
 	CrawledPages []CrawledPage `json:"crawledPages"`
+	Partial      bool          `json:"partial,omitempty"`     // crawling of the level was interrupted
+	StopReasons  []string      `json:"stopReasons,omitempty"` // STOP_REASON_* of budget limits hit at the level
+}
+
+// Settings of a single crawling
+type Crawler struct {
+	IncludeSubdomains bool
+	Validator         validator.Validator
+	Fetcher           *Fetcher // DefaultFetcher if nil
+	IgnoreRobots      bool     // do not consult robots.txt
+	Politeness        PolitenessConfig
+	Retry             RetryPolicy
+	Budget            Budget
+	Normalizer        normalizer.Normalizer // normalizer.Default if nil, shared by all the url dedupe
+	Extractors        []Extractor           // DefaultExtractors if nil, append to them to add custom values
+	NoFollowPolicy    string                // one of NOFOLLOW_*, NOFOLLOW_FOLLOW if empty or unknown
+	AllowedMimeTypes  []string              // mime types of the pages to parse, DEFAULT_HTML_MIME_TYPES if empty
+	MaxBodySize       int64                 // body bytes to parse, MAX_PAGE_SIZE if not positive
+	Renderer          Renderer              // gets the pages, Fetcher if nil, see ChromeRenderer
+
+	robotsMu      sync.Mutex
+	robotsCache   map[string]*robotsEntry // robots.txt by scheme://host
+	schedulerOnce sync.Once
+	scheduler     *scheduler
+}
+
+func NewCrawler(includeSubdomains bool, validator validator.Validator, fetcher *Fetcher) *Crawler {
+	return &Crawler{
+		IncludeSubdomains: includeSubdomains,
+		Validator:         validator,
+		Fetcher:           fetcher,
+		Politeness:        DefaultPolitenessConfig(),
+		Retry:             DefaultRetryPolicy(),
+	}
+}
+
+func (c *Crawler) normalizer() normalizer.Normalizer {
+	if c.Normalizer == nil {
+		return normalizer.Default
+	}
+	return c.Normalizer
+}
+
+// Returns normalized link or the link as it is if it can't be normalized
+func (c *Crawler) normalize(link string) string {
+	if normalized, err := c.normalizer().Normalize(link); err == nil {
+		return normalized
+	}
+	return strings.TrimSpace(link)
+}
+
+func (c *Crawler) fetcher() *Fetcher {
+	if c.Fetcher == nil {
+		return DefaultFetcher
+	}
+	return c.Fetcher
+}
+
+func (c *Crawler) renderer() Renderer {
+	if c.Renderer == nil {
+		return c.fetcher()
+	}
+	return c.Renderer
 }
 
 func ParsePage(url string) (CrawledPage, error) {
+	return ParsePageContext(context.Background(), url)
+}
+
+// Works like ParsePage, but the request is bound to the ctx,
+// so the page fetching is aborted as soon as ctx is cancelled
+func ParsePageContext(ctx context.Context, url string) (CrawledPage, error) {
+	return (&Crawler{}).ParsePage(ctx, url)
+}
+
+// Parses the page by url. The failed page is returned with the error details along with the error.
+// Transient failures of the body reading are retried as the request ones, see RetryPolicy
+func (c *Crawler) ParsePage(ctx context.Context, url string) (CrawledPage, error) {
 	// Check the time
 	start := time.Now()
 
 	// Ensure url is ok
-	url = utils.AddFollowingSlashToUrl(url)
+	url = c.normalize(url)
+
+	for attempts := 0; ; {
+		crawledPage, readErr, err := c.parsePage(ctx, url, start, attempts)
+		attempts = crawledPage.Attempts
+		if readErr == nil || ctx.Err() != nil || !isRetryableError(readErr) || attempts >= c.Retry.maxAttempts() {
+			return crawledPage, err
+		}
+		if !c.waitRetry(ctx, url, attempts, readErr.Error()) {
+			return crawledPage, err
+		}
+	}
+}
+
+// Single try of ParsePage after the madeAttempts. Returns the error of the body reading separately
+func (c *Crawler) parsePage(ctx context.Context, url string, start time.Time, madeAttempts int) (
+	crawledPage CrawledPage, readErr error, err error) {
+	// Init future result
+	crawledPage = CrawledPage{
+		Url:            url,
+		Links:          make([]string, 0),
+		LinksInfo:      make([]LinkInfo, 0),
+		HreflangUrlMap: make(map[string]string),
+		Imgs:           make([]string, 0),
+		Assets:         make([]Asset, 0),
+	}
+	failed := func(kind string, err error) (CrawledPage, error, error) {
+		crawledPage.ErrorKind = kind
+		crawledPage.Error = err.Error()
+		crawledPage.FetchTimeMs = time.Now().Sub(start).Nanoseconds() / 1E+6
+		return crawledPage, nil, errors.New("Failed to crawl1 " + url + " with error: \"" + err.Error() + "\"")
+	}
 
 	// Get page by url
-	resp, err := http.Get(url)
+	fetchCtx, redirects := withRedirectRecorder(ctx)
+	resp, attempts, err := c.fetchAfter(fetchCtx, url, c.renderer(), madeAttempts)
+	crawledPage.Attempts = attempts
+	crawledPage.RedirectChain = redirects.chain()
 
 	// Handle response errors
 	if err != nil {
 		notifyAboutUrlWithTime(url, start, true, "")
-		errMessage := "Failed to crawl1 " + url + " with error: \"" + err.Error() + "\""
-		return CrawledPage{}, errors.New(errMessage)
+		return failed(classifyError(ctx, err), err)
+	}
+	defer resp.Body.Close()
+
+	// Response details
+	crawledPage.StatusCode = resp.StatusCode
+	crawledPage.ContentType = resp.Header.Get("Content-Type")
+	crawledPage.FinalUrl = c.normalize(resp.Request.URL.String())
+	_, crawledPage.Encoding = transferInfo(resp)
+	if resp.ContentLength > 0 {
+		crawledPage.Size = resp.ContentLength
 	}
 
 	// Handle not 200 status of original query or last redirect
 	if resp.StatusCode != 200 {
+		crawledPage.TransferSize = drainBody(resp, resp.Body)
 		notifyAboutUrlWithTime(url, start, false, resp.Status)
-		errMessage := "Failed to crawl1 " + url + " with error: \"Not 200 status code(" + strconv.Itoa(resp.StatusCode) + ")\""
-		return CrawledPage{}, errors.New(errMessage)
+		return failed(ERR_KIND_STATUS, errors.New("Not 200 status code("+strconv.Itoa(resp.StatusCode)+")"))
 	}
 
-	// Create goquery Document
-	respBodyReader := resp.Body
-	// [Debug] uncomment to see html code
-	// [!] Note, io.Reader can be used once - goquery won't find elements further
-	/*html, err := ioutil.ReadAll(respBodyReader)
-	log.Print(html)*/
-	doc, err := goquery.NewDocumentFromReader(respBodyReader)
-	if err != nil {
-		errMessage := "Failed to create goquery Document from " + url + " with error: \"" + err.Error() + "\""
-		return CrawledPage{}, errors.New(errMessage)
+	// Not html content is recorded as a resource without downloading, but the first DRAIN_BODY_SIZE bytes
+	body := bufio.NewReaderSize(resp.Body, CHARSET_SNIFF_LEN)
+	crawledPage.MimeType = detectMimeType(resp, body)
+	if !c.isAllowedMimeType(crawledPage.MimeType) {
+		crawledPage.Resource = true
+		crawledPage.TransferSize = drainBody(resp, body)
+		notifyAboutUrlWithTime(url, start, false, resp.Status)
+		crawledPage.FetchTimeMs = time.Now().Sub(start).Nanoseconds() / 1E+6
+		return crawledPage, nil, nil
 	}
 
-	// Init future result
-	crawledPage := CrawledPage{"", "", "", make([]string, 0),
-		make(map[string]string), make([]string, 0), "", false}
-
-	/* Find data */
-
-	// Grab url
-	// Get original url or the last redirect
-	crawledPage.Url = utils.AddFollowingSlashToUrl(strings.TrimSpace(resp.Request.URL.String()))
-
-	// Grab title
-	title := doc.Find("title").Eq(0).Text()
-	title = strings.TrimSpace(title)
-	crawledPage.Title = title
-
-	// Grab h1
-	h1 := doc.Find("h1").Eq(0).Text()
-	h1 = strings.TrimSpace(h1)
-	crawledPage.H1 = h1
-
-	// Grab links
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		link, exists := s.Attr("href")
-		if exists {
-			link = strings.TrimSpace(link)
-			crawledPage.Links = append(crawledPage.Links, link)
-		}
-	})
-	// Extend relative links
-	foo := make([]string, 0, len(crawledPage.Links))
-	for _, link := range crawledPage.Links {
-		if extendedLink, err := ExtendRelativeLink(link, url); err == nil {
-			foo = append(foo, extendedLink)
-		} /*else { // [Debug] Uncomment to see errors of relative extension
-			log.Println(err.Error())
-		}*/
-	}
-	crawledPage.Links = foo
-
-	// Grab hreflangs
-	doc.Find("link[rel *= 'alternate']").Each(func(i int, s *goquery.Selection) {
-		hreflang, exists := s.Attr("hreflang")
-		if !exists {
-			return
-		}
-
-		href, exists := s.Attr("href")
-		if !exists {
-			return
-		}
-
-		hreflang = strings.TrimSpace(hreflang)
-		href = strings.TrimSpace(href)
-		href, err = ExtendRelativeLink(href, url)
-		if err != nil {
-			panic(err.Error())
-		}
-
-		crawledPage.HreflangUrlMap[hreflang] = href
-		crawledPage.Links = append(crawledPage.Links, href)
-	})
-
-	// Grab imgs
-	doc.Find("img").Each(func(i int, s *goquery.Selection) {
-		imgSrc, exists := s.Attr("src")
-		imgSrc = strings.TrimSpace(imgSrc)
-		if exists {
-			crawledPage.Imgs = append(crawledPage.Imgs, imgSrc)
-		}
-	})
+	// Detect the encoding, the page is extracted from UTF-8
+	head, _ := body.Peek(CHARSET_SNIFF_LEN)
+	enc, charsetName := detectCharset(crawledPage.ContentType, head)
+	crawledPage.Charset = charsetName
+	if bytes.HasPrefix(head, UTF8_BOM) {
+		_, _ = body.Discard(len(UTF8_BOM))
+	}
 
-	// Grab canonical url
-	canonicalUrl, exists := doc.Find("link[rel *= 'canonical']").Eq(0).Attr("href")
-	if exists {
-		canonicalUrl, err = ExtendRelativeLink(strings.TrimSpace(canonicalUrl), url)
-		if err != nil {
-			return CrawledPage{}, err
+	// Read html tokens, no more than MaxBodySize bytes
+	respBodyReader := &countingReader{Reader: io.LimitReader(body, c.maxBodySize())}
+	doc := &Document{Url: resp.Request.URL, Response: resp}
+	err = extractPage(utf8Reader(respBodyReader, enc, charsetName), doc, c.extractors(), &crawledPage)
+	crawledPage.Size = respBodyReader.Count
+	crawledPage.TransferSize, _ = transferInfo(resp)
+	if err != nil {
+		if ctx.Err() == nil && !isRetryableError(err) {
+			err = errors.New("Failed to parse html: " + err.Error())
 		}
-		crawledPage.CanonicalUrl = canonicalUrl
-		crawledPage.Links = append(crawledPage.Links, canonicalUrl)
+		page, _, failedErr := failed(classifyError(ctx, err), err)
+		return page, err, failedErr
 	}
 
-	// Grab noindex
-	_, exists = doc.Find("meta[content *= 'noindex']").Eq(0).Attr("content")
-	if exists {
-		crawledPage.NoIndex = true
-	}
+	c.applyNoFollowPolicy(&crawledPage)
 
 	// Checking pagination pattern
-	r := regexp.MustCompile(`^((http|https):\/\/.*\/)(page|p)\/\d+\/$`)
-	if paginationRootMatched := r.FindStringSubmatch(url); paginationRootMatched != nil {
-		paginationRoot := utils.AddFollowingSlashToUrl(paginationRootMatched[1])
-		crawledPage.Links = append(crawledPage.Links, paginationRoot)
+	if paginationRootMatched := PAGINATION_REGEXP.FindStringSubmatch(url); paginationRootMatched != nil {
+		crawledPage.Links = append(crawledPage.Links, paginationRootMatched[1])
 	}
 
 	// Checking get parameters pattern
 	if strings.Contains(url, `?`) {
-		withoutGet := utils.AddFollowingSlashToUrl(strings.Split(url, `?`)[0])
+		withoutGet := strings.Split(url, `?`)[0]
 		crawledPage.Links = append(crawledPage.Links, withoutGet)
 	}
 
 	notifyAboutUrlWithTime(url, start, false, resp.Status)
+	crawledPage.FetchTimeMs = time.Now().Sub(start).Nanoseconds() / 1E+6
 
-	// Cleanup
-	err = respBodyReader.Close()
-	if err != nil {
-		return CrawledPage{}, err
-	}
+	return crawledPage, nil, nil
+}
 
-	return crawledPage, nil
+// Url waiting in the frontier with the depth it was found at
+type frontierItem struct {
+	url   string
+	depth int
 }
 
-func worker(id int, tasks <-chan string, results chan<- CrawledPage) {
-	for t := range tasks {
-		cp, err := ParsePage(t)
-		if err != nil {
-			results <- CrawledPage{}
-		} else {
-			results <- cp
+// Crawled page with the links to crawl at the next depth
+type frontierResult struct {
+	item      frontierItem
+	page      CrawledPage
+	nextLinks []string
+	aborted   bool // crawling was cancelled while the page was being fetched
+}
+
+func (c *Crawler) worker(ctx context.Context, id int, domain string, tasks <-chan frontierItem,
+	results chan<- frontierResult, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for item := range tasks {
+		cp, err := c.ParsePage(ctx, item.url)
+		if err != nil && ctx.Err() != nil { // page was aborted by cancellation, it's not a failure
+			results <- frontierResult{item: item, aborted: true}
+			continue
 		}
+		results <- frontierResult{item: item, page: cp, nextLinks: c.filterNextLinks(ctx, domain, c.linksToFollow(cp))}
 	}
 }
 
-func Crawl(linksToCrawl []string, crawledLinks []string,
-	crawledLevels []CrawledLevel, includeSubdomains bool, validator validator.Validator) []CrawledLevel {
+func Crawl(linksToCrawl []string, includeSubdomains bool, validator validator.Validator) []CrawledLevel {
+	crawledLevels, _ := CrawlContext(context.Background(), linksToCrawl, includeSubdomains, validator)
+
+	return crawledLevels
+}
+
+// Works like Crawl, but stops crawling as soon as ctx is cancelled or its deadline is exceeded.
+// Pages which are being fetched at that moment are aborted and workers are drained.
+// Returns levels crawled so far, the unfinished of them are marked as partial,
+// and ctx.Err() as the marker of interrupted crawling
+func CrawlContext(ctx context.Context, linksToCrawl []string, includeSubdomains bool,
+	validator validator.Validator) ([]CrawledLevel, error) {
+	return NewCrawler(includeSubdomains, validator, nil).Crawl(ctx, linksToCrawl)
+}
+
+// Crawls linksToCrawl and all the links found on the pages with the crawler settings, see CrawlContext.
+// Urls are taken from the frontier queue by long-lived workers as soon as they are free,
+// the pages are grouped to levels by the depth they were found at
+func (c *Crawler) Crawl(ctx context.Context, linksToCrawl []string) ([]CrawledLevel, error) {
+	if len(linksToCrawl) == 0 {
+		return []CrawledLevel{}, nil
+	}
+
 	log.Print("[crawler]\tStarting crawl ", len(linksToCrawl), " links")
 
-	// To be sure that all links to crawl has following '/'
-	foo := make([]string, 0, len(linksToCrawl))
+	// Frontier: queue of urls to crawl and the set of urls already queued or crawled
+	queue := make([]frontierItem, 0, len(linksToCrawl))
+	seen := make(map[string]struct{})
 	for _, link := range linksToCrawl {
-		foo = append(foo, utils.AddFollowingSlashToUrl(link))
+		link = c.normalize(link)
+		if _, ok := seen[link]; !ok {
+			seen[link] = struct{}{}
+			queue = append(queue, frontierItem{url: link, depth: 0})
+		}
 	}
-	linksToCrawl = foo
 
+	pagesByDepth := make(map[int][]CrawledPage)
+	partialDepths := make(map[int]struct{})
+	reasonsByDepth := make(map[int]map[string]struct{})
+	addStopReason := func(depth int, reason string) {
+		if reasonsByDepth[depth] == nil {
+			reasonsByDepth[depth] = make(map[string]struct{})
+		}
+		reasonsByDepth[depth][reason] = struct{}{}
+	}
 	notGotPages := 0
-	crawledPages := make([]CrawledPage, 0)
+	crawledPagesNum := 0
+
+	// Budget of the crawling, the time limit aborts the pages in progress
+	budget := newBudgetTracker(c.Budget)
+	crawlCtx, crawlCancel := context.WithCancel(ctx)
+	defer crawlCancel()
+	var durationCh <-chan time.Time
+	if c.Budget.MaxDuration > 0 {
+		durationTimer := time.NewTimer(c.Budget.MaxDuration)
+		defer durationTimer.Stop()
+		durationCh = durationTimer.C
+	}
 
 	// Define channels
-	tasksCh := make(chan string)
-	resultsCh := make(chan CrawledPage)
+	tasksCh := make(chan frontierItem)
+	resultsCh := make(chan frontierResult)
 
 	// Run workers
-	for j := 0; j < PARALLEL_LVL; j++ {
-		go worker(j, tasksCh, resultsCh)
-	}
-
-	// Feeds crawling tasks as soon as workers can consume it
-	go func() {
-		for _, link := range linksToCrawl {
-			link = utils.AddFollowingSlashToUrl(link)
-			tasksCh <- link
-			crawledLinks = append(crawledLinks, link)
+	domain := utils.ExtractDomain(queue[0].url)
+	wg := &sync.WaitGroup{}
+	for j := 0; j < c.Politeness.workers(); j++ {
+		wg.Add(1)
+		go c.worker(crawlCtx, j, domain, tasksCh, resultsCh, wg)
+	}
+
+	// Feed workers from the frontier and extend it with the results until nothing is left
+	inProgress := 0
+	cancelled := false
+	done := ctx.Done()
+	var next *frontierItem // taken from the frontier and charged to the budget, waiting for a free worker
+	for {
+		// Take the next url if crawling is not cancelled and the budget allows
+		for next == nil && len(queue) > 0 && !cancelled {
+			ok, reason := budget.take(queue[0].url)
+			if ok {
+				item := queue[0]
+				next = &item
+			} else if reason == STOP_REASON_MAX_PAGES_PER_HOST {
+				partialDepths[queue[0].depth] = struct{}{}
+				addStopReason(queue[0].depth, reason)
+			} else { // the whole budget is exhausted, the rest of the frontier stays not crawled
+				break
+			}
+			queue = queue[1:]
 		}
-		close(tasksCh)
-	}()
-
-	// Waiting for results
-	for range linksToCrawl {
-		crawledPage := <-resultsCh
-		if crawledPage.IsEmpty() {
-			notGotPages++
+		if next == nil && inProgress == 0 { // nothing to crawl and nothing to wait for
+			break
+		}
+		var sendCh chan<- frontierItem
+		var nextItem frontierItem
+		if next != nil {
+			sendCh = tasksCh
+			nextItem = *next
 		}
-		crawledPages = append(crawledPages, crawledPage)
-		crawledLinks = append(crawledLinks, crawledPage.Url)
-	}
 
-	// Unique crawledLinks
-	crawledLinks = utils.UniqueStringSlice(crawledLinks)
+		select {
+		case sendCh <- nextItem:
+			next = nil
+			inProgress++
+		case result := <-resultsCh:
+			inProgress--
+			if result.aborted {
+				partialDepths[result.item.depth] = struct{}{}
+				if budget.stopReason != "" {
+					addStopReason(result.item.depth, budget.stopReason)
+				}
+				continue
+			}
 
-	log.Print("[crawler]\tCrawled with error ", notGotPages, "/", len(linksToCrawl), " links")
+			crawledPagesNum++
+			if result.page.IsFailed() {
+				notGotPages++
+			}
+			budget.spend(result.page)
+			pagesByDepth[result.item.depth] = append(pagesByDepth[result.item.depth], result.page)
+			if result.page.FinalUrl != "" {
+				seen[result.page.FinalUrl] = struct{}{}
+			}
 
-	// Add the new crawled level to crawledLevels
-	var lastLevelNum int
-	if len(crawledLevels) > 0 {
-		lastLevelNum = crawledLevels[0].LevelNum
-	} else {
-		lastLevelNum = -1
+			// Extend the frontier with not seen links
+			for _, link := range result.nextLinks {
+				if _, ok := seen[link]; ok {
+					continue
+				}
+				if !budget.allowsDepth(result.item.depth + 1) {
+					addStopReason(result.item.depth, STOP_REASON_MAX_DEPTH)
+					continue
+				}
+				seen[link] = struct{}{}
+				queue = append(queue, frontierItem{url: link, depth: result.item.depth + 1})
+			}
+		case <-durationCh:
+			budget.stop(STOP_REASON_MAX_DURATION)
+			cancelled = true
+			crawlCancel() // abort the pages in progress
+		case <-done:
+			cancelled = true
+			done = nil // stop feeding, wait for the pages in progress
+		}
+	}
+	close(tasksCh)
+	wg.Wait()
+
+	log.Print("[crawler]\tCrawled with error ", notGotPages, "/", crawledPagesNum, " links")
+	if budget.stopReason != "" {
+		log.Print("[crawler]\tCrawling budget is exhausted: ", budget.stopReason)
+	}
+
+	// Not crawled urls left in the frontier make their levels partial
+	if next != nil {
+		queue = append(queue, *next)
+	}
+	for _, item := range queue {
+		partialDepths[item.depth] = struct{}{}
+		if budget.stopReason != "" {
+			addStopReason(item.depth, budget.stopReason)
+		}
 	}
-	crawledLevels = append(crawledLevels, CrawledLevel{
-		LevelNum:     lastLevelNum + 1,
-		CrawledPages: crawledPages,
-	})
 
-	// Collect and unique all links from crawled pages
-	nextLevelLinksMap := make(map[string]struct{}, 0)
-	for _, page := range crawledPages {
-		for _, link := range page.Links {
-			nextLevelLinksMap[link] = struct{}{}
+	// Group pages to levels by depth
+	depths := make([]int, 0, len(pagesByDepth))
+	for depth := range pagesByDepth {
+		depths = append(depths, depth)
+	}
+	for depth := range partialDepths {
+		if _, ok := pagesByDepth[depth]; !ok {
+			depths = append(depths, depth)
+		}
+	}
+	sort.Ints(depths)
+	crawledLevels := make([]CrawledLevel, 0, len(depths))
+	for _, depth := range depths {
+		_, partial := partialDepths[depth]
+		crawledPages := pagesByDepth[depth]
+		if crawledPages == nil {
+			crawledPages = make([]CrawledPage, 0)
+		}
+		var stopReasons []string
+		for reason := range reasonsByDepth[depth] {
+			stopReasons = append(stopReasons, reason)
 		}
+		sort.Strings(stopReasons)
+		crawledLevels = append(crawledLevels, CrawledLevel{
+			LevelNum:     depth,
+			CrawledPages: crawledPages,
+			Partial:      partial,
+			StopReasons:  stopReasons,
+		})
 	}
-	// Convert map to a slice
-	nextLevelLinks := make([]string, 0, len(nextLevelLinksMap))
-	for k := range nextLevelLinksMap {
-		nextLevelLinks = append(nextLevelLinks, k)
+
+	// Crawling was cancelled
+	if err := ctx.Err(); err != nil {
+		log.Print("[crawler]\tCrawling has been interrupted: ", err.Error())
+		return crawledLevels, err
 	}
+
+	return crawledLevels, nil
+}
+
+// Prepares links found on a page to be crawled: removes bad links and fragments,
+// validates them with the crawler rules, domain and robots.txt
+func (c *Crawler) filterNextLinks(ctx context.Context, domain string, links []string) []string {
 	// Filter out bad links(tel:, mailto:, #, etc.)
-	nextLevelLinks = utils.FilterSlice(nextLevelLinks, func(link string) bool {
+	nextLinks := utils.FilterSlice(links, func(link string) bool {
 		if link == "" || link == "#" {
 			return false
 		} else if strings.HasPrefix(link, "tel:") ||
@@ -287,152 +514,46 @@ func Crawl(linksToCrawl []string, crawledLinks []string,
 		return true
 	})
 
-	// Extract part before # + add the following "/"
-	foo = make([]string, 0, len(nextLevelLinks))
-	for _, link := range nextLevelLinks {
-		foo = append(foo, utils.AddFollowingSlashToUrl(utils.ExtractUrlBeforeSharp(link)))
+	// Normalize links, fragments are removed by the default rules
+	normalizedLinks := make([]string, 0, len(nextLinks))
+	for _, link := range nextLinks {
+		if normalized, err := c.normalizer().Normalize(link); err == nil {
+			normalizedLinks = append(normalizedLinks, normalized)
+		}
 	}
-	nextLevelLinks = foo
+	nextLinks = normalizedLinks
 
 	// Remove duplicates
-	nextLevelLinks = utils.UniqueStringSlice(nextLevelLinks)
-	// Validate nextLevelLinks
-	nextLevelLinks = utils.FilterSlice(nextLevelLinks, func(link string) bool {
-		return validator.IsValid(link)
+	nextLinks = utils.UniqueStringSlice(nextLinks)
+	// Validate nextLinks
+	nextLinks = utils.FilterSlice(nextLinks, func(link string) bool {
+		return c.Validator.IsValid(link)
 	})
 	// Validate with domain pattern, subdomains handled
-	domain := utils.ExtractDomain(linksToCrawl[0])
-	nextLevelLinks = utils.FilterLinksNotInDomain(domain, nextLevelLinks, includeSubdomains)
+	nextLinks = utils.FilterLinksNotInDomain(domain, nextLinks, c.IncludeSubdomains)
 	// Filter out image links
-	nextLevelLinks = utils.FilterLinksToImages(nextLevelLinks)
-
-	// Convert crawledLinks to map to be able to search in it
-	crawledMap := make(map[string]struct{}, len(crawledLinks))
-	for _, link := range crawledLinks {
-		crawledMap[link] = struct{}{}
-	}
-	// Separate not crawled links from nextLevelLinks
-	remainingLinks := make([]string, 0, len(nextLevelLinks))
-	for _, link := range nextLevelLinks {
-		_, ok := crawledMap[link]
-		if !ok { // link is not already crawled
-			remainingLinks = append(remainingLinks, link)
-		}
-	}
+	nextLinks = utils.FilterLinksToImages(nextLinks)
+	// Filter out links disallowed by robots.txt
+	nextLinks = c.FilterDisallowedByRobots(ctx, nextLinks)
 
-	if len(remainingLinks) == 0 { // crawling is done
-		return crawledLevels
-	} else {
-		return Crawl(remainingLinks, crawledLinks, crawledLevels, includeSubdomains, validator) // crawl next level
-	}
+	return nextLinks
 }
 
-func ExtendRelativeLink(relativeLink string, linkAbsoluteLocation string) (absoluteUrl string, err error) {
-	// If relativeLink is empty
-	if relativeLink == "" {
-		return "", errors.New("Empty relativeLink")
-	}
-
-	// To bee sure that input URLs has trailing '/'
-	relativeLink = utils.AddFollowingSlashToUrl(relativeLink)
-	linkAbsoluteLocation = utils.ExtractUrlBeforeSharp(linkAbsoluteLocation)     // part before #
-	linkAbsoluteLocation = utils.ExtractUrlBeforeQuestMark(linkAbsoluteLocation) // part before ?
-	linkAbsoluteLocation = utils.AddFollowingSlashToUrl(linkAbsoluteLocation)
-
-	// Common data for the all cases
-	absoluteSplitted := strings.Split(linkAbsoluteLocation, `/`)
-	protocol := absoluteSplitted[0] + "//"
-	domain := utils.AddFollowingSlashToUrl(utils.ExtractDomain(linkAbsoluteLocation))
-
-	// Case relativeLink is already absolute
-	pattern := `^(http|https):\/\/.*$`
-	r := regexp.MustCompile(pattern)
-	if r.MatchString(relativeLink) {
-		return relativeLink, nil
-	}
-
-	// Extract clean path and params(#.., ?..) from relative url
-	cleanRelative := relativeLink
-	relativeParams := "" // #... or ?...
-
-	tagR := regexp.MustCompile(`^(.*)([#].*)$`)
-	if tag := tagR.FindStringSubmatch(cleanRelative); tag != nil {
-		cleanRelative = tag[1]
-		relativeParams = tag[2] + relativeParams
-	}
-	questR := regexp.MustCompile(`^(.*)([?].*)$`)
-	if quest := questR.FindStringSubmatch(cleanRelative); quest != nil {
-		cleanRelative = quest[1]
-		relativeParams = quest[2] + relativeParams
-	}
-
-	// Case ?id=1, #Header
-	if cleanRelative == `` {
-		return linkAbsoluteLocation + relativeParams, nil
-	}
-
-	// Case /
-	if cleanRelative == `/` { // root
-		return protocol + domain + relativeParams, nil
-	}
-
-	// Case a/, path/to/page/, about/, path/page.htm
-	r = regexp.MustCompile(`^(\w[.\w-]*/?)+$`)
-	if r.MatchString(cleanRelative) { // relative to location
-		if utils.IsFile(linkAbsoluteLocation) {
-			r = regexp.MustCompile(`^(.*/).*\..*$`)
-			if newAbsolute := r.FindStringSubmatch(linkAbsoluteLocation); newAbsolute != nil {
-				return newAbsolute[1] + cleanRelative + relativeParams, nil
-			}
-			return "", errors.New("Can't parse relative link: \"" + relativeLink +
-				"\" with absolute location: \"" + linkAbsoluteLocation + "\"")
-		} else {
-			return linkAbsoluteLocation + cleanRelative + relativeParams, nil
-		}
-	}
-
-	// Case /a/, /path/to/page/, /about/ /path/page.htm
-	r = regexp.MustCompile(`^/((\w[.\w-]*/?)+)$`)
-	if newRelative := r.FindStringSubmatch(cleanRelative); newRelative != nil { // relative to root
-		return protocol + domain + newRelative[1] + relativeParams, nil
-	}
-
-	// Case //path/to/smth //path/page.htm
-	r = regexp.MustCompile(`^//(.*)$`)
-	if newLink := r.FindStringSubmatch(relativeLink); newLink != nil { // same protocol link
-		return protocol + newLink[1], nil
-	}
-
-	// Case ../path/ ../path/page.htm
-	r = regexp.MustCompile(`^\.\./(.*)$`)
-	if newRelative := r.FindStringSubmatch(relativeLink); newRelative != nil {
-		newRelativePart := newRelative[1]
-		if len(absoluteSplitted) < 4 { // less than 4 levels in absolute: (http:)()(domain.com)(first_level)
-			return "", errors.New("Cannot resolve '../' relative path for absolute location " +
-				linkAbsoluteLocation)
-		} else {
-			var newAbsoluteSplitted []string
-
-			if utils.ExtractLastChar(linkAbsoluteLocation) == "/" {
-				newAbsoluteSplitted = absoluteSplitted[:len(absoluteSplitted)-2] // -2 because we have '' after last /
-			} else {
-				newAbsoluteSplitted = absoluteSplitted[:len(absoluteSplitted)-1] // .htm case
-			}
-			newAbsolute := utils.AddFollowingSlashToUrl(strings.Join(newAbsoluteSplitted, "/"))
-
-			return newAbsolute + newRelativePart, nil
-		}
-	}
-
-	return "", errors.New("Can't parse relative link: \"" + relativeLink + "\"")
+// Returns unique urls(after redirects) of successfully crawled pages normalized by the default normalizer
+func ExtractUniqueLinks(levels []CrawledLevel) (uniqueLinks []string) {
+	return (&Crawler{}).ExtractUniqueLinks(levels)
 }
 
-func ExtractUniqueLinks(levels []CrawledLevel) (uniqueLinks []string) {
+// Returns unique urls(after redirects) of successfully crawled pages normalized by the crawler normalizer
+func (c *Crawler) ExtractUniqueLinks(levels []CrawledLevel) (uniqueLinks []string) {
 	uniqueLinksMap := make(map[string]struct{})
 
 	for _, lvl := range levels {
 		for _, page := range lvl.CrawledPages {
-			uniqueLinksMap[page.Url] = struct{}{}
+			if page.IsFailed() {
+				continue
+			}
+			uniqueLinksMap[c.normalize(page.FinalUrl)] = struct{}{}
 		}
 	}
 	for k, _ := range uniqueLinksMap {
@@ -457,48 +578,3 @@ func notifyAboutUrlWithTime(url string, startTime time.Time, error bool, statusC
 
 	log.Print("[crawler]\t" + message)
 }
-
-func GetLinksFromSitemap(siteMainPageUrl string) (sitemapLinks []string, err error) {
-	// Fix url
-	siteMainPageUrl = utils.AddFollowingSlashToUrl(siteMainPageUrl)
-	sitemapUrl := siteMainPageUrl + "sitemap.xml"
-
-	// Get sitemap content
-	resp, err := http.Get(sitemapUrl)
-
-	// Handle response errors
-	if err != nil {
-		errMessage := "Failed to read sitemap: \"" + sitemapUrl + "\" with error: \"" + err.Error() + "\""
-		return nil, errors.New(errMessage)
-	}
-
-	// Handle not 200 status of original query or last redirect
-	if resp.StatusCode != 200 {
-		errMessage := "Failed to read sitemap: \"" + sitemapUrl + "\" with error: \"Not 200 status code(" +
-			strconv.Itoa(resp.StatusCode) + ")\""
-		return nil, errors.New(errMessage)
-	}
-
-	// Read sitemap
-	scanner := bufio.NewScanner(resp.Body)
-
-	r := regexp.MustCompile(`^.*<loc>(.*)</loc>.*$`)
-
-	for scanner.Scan() {
-		link := scanner.Text()
-		if extrUrl := r.FindStringSubmatch(link); extrUrl != nil {
-			sitemapLinks = append(sitemapLinks, utils.AddFollowingSlashToUrl(extrUrl[1]))
-		}
-	}
-
-	sitemapLinks = utils.UniqueStringSlice(sitemapLinks)
-
-	err = resp.Body.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	log.Print("[crawler]\tFound ", len(sitemapLinks), " unique links at "+sitemapUrl)
-
-	return sitemapLinks, nil
-}
@@ -0,0 +1,220 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Host of the test site, it's served through the proxy as the domain filtering doesn't support ip hosts
+const TEST_SITE_URL = "http://example.com"
+
+// Links of the test site pages: "/" -> "/a", "/b" -> "/a1", "/a2", "/b1" -> "/"
+var TEST_SITE = map[string][]string{
+	"/":   {"/a", "/b"},
+	"/a":  {"/a1", "/a2", "/b"},
+	"/b":  {"/b1"},
+	"/a1": {"/"},
+	"/a2": {},
+	"/b1": {"/a"},
+}
+
+// Serves TEST_SITE, "/slow" is answered when its request is cancelled or started is closed.
+// started gets a value when "/slow" is requested
+func testSite(t *testing.T, slow chan struct{}, started chan<- struct{}) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			if started != nil {
+				started <- struct{}{}
+			}
+			select {
+			case <-slow:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		links, ok := TEST_SITE[r.URL.Path]
+		if !ok && r.URL.Path != "/slow" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		body := "<html><head><title>" + r.URL.Path + "</title></head><body>"
+		for _, link := range links {
+			body += `<a href="` + link + `">` + link + `</a>`
+		}
+		w.Write([]byte(body + "</body></html>"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Crawler of TEST_SITE_URL served by srv
+func testCrawler(t *testing.T, srv *httptest.Server) *Crawler {
+	fetcher, err := NewFetcher(FetcherConfig{ProxyUrl: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Crawler{Fetcher: fetcher, Politeness: PolitenessConfig{Workers: 3}}
+}
+
+// Returns paths of the level pages in alphabetical order
+func levelPaths(level CrawledLevel) string {
+	paths := make([]string, 0, len(level.CrawledPages))
+	for _, page := range level.CrawledPages {
+		paths = append(paths, strings.TrimPrefix(page.Url, TEST_SITE_URL))
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, " ")
+}
+
+// Answer of the fixtureServer
+type fixture struct {
+	status int               // 200 if 0
+	header map[string]string // Content-Type is text/html if not set
+	body   string            // "{srv}" is replaced with the server url
+	gzip   bool              // body is sent gzipped
+	cut    bool              // connection is closed in the middle of the body
+}
+
+// Serves the fixtures by path, the n-th request of a path gets the n-th fixture and the last one is repeated.
+// Not listed paths are not found
+type fixtureServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newFixtureServer(t *testing.T, fixtures map[string][]fixture) *fixtureServer {
+	srv := &fixtureServer{requests: make(map[string]int)}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		srv.requests[r.URL.Path]++
+		n := srv.requests[r.URL.Path]
+		srv.mu.Unlock()
+
+		answers, ok := fixtures[r.URL.Path]
+		if !ok || len(answers) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if n > len(answers) {
+			n = len(answers)
+		}
+		f := answers[n-1]
+
+		body := []byte(strings.Replace(f.body, "{srv}", srv.URL, -1))
+		if f.gzip {
+			body = gzipped(string(body))
+		}
+		w.Header().Set("Content-Type", "text/html")
+		for name, value := range f.header {
+			w.Header().Set(name, value)
+		}
+		if f.status == 0 {
+			f.status = http.StatusOK
+		}
+		if f.cut {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(f.status)
+			w.Write(body[:len(body)/2])
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.WriteHeader(f.status)
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Returns the number of the path requests
+func (s *fixtureServer) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+func TestCrawlLevels(t *testing.T) {
+	srv := testSite(t, nil, nil)
+
+	levels, err := testCrawler(t, srv).Crawl(context.Background(), []string{TEST_SITE_URL + "/", TEST_SITE_URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/", "/a /b", "/a1 /a2 /b1"}
+	if len(levels) != len(expected) {
+		t.Fatalf("levels = %d, expected %d", len(levels), len(expected))
+	}
+	for i, level := range levels {
+		if level.LevelNum != i || level.Partial || len(level.StopReasons) != 0 {
+			t.Errorf("level %d: num %d, partial %v, stop reasons %v", i, level.LevelNum, level.Partial,
+				level.StopReasons)
+		}
+		if paths := levelPaths(level); paths != expected[i] {
+			t.Errorf("level %d pages = %q, expected %q", i, paths, expected[i])
+		}
+	}
+	if links := ExtractUniqueLinks(levels); len(links) != len(TEST_SITE) {
+		t.Errorf("unique links = %v", links)
+	}
+}
+
+func TestCrawlWithoutLinks(t *testing.T) {
+	levels, err := (&Crawler{}).Crawl(context.Background(), nil)
+	if err != nil || len(levels) != 0 {
+		t.Errorf("levels %v, err %v", levels, err)
+	}
+}
+
+func TestCrawlCancelled(t *testing.T) {
+	started := make(chan struct{}, 1)
+	srv := testSite(t, make(chan struct{}), started)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	levels, err := testCrawler(t, srv).Crawl(ctx, []string{TEST_SITE_URL + "/a2", TEST_SITE_URL + "/slow"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, expected %v", err, context.Canceled)
+	}
+	if len(levels) != 1 || !levels[0].Partial {
+		t.Fatalf("levels = %+v", levels)
+	}
+	for _, page := range levels[0].CrawledPages {
+		if page.Url == TEST_SITE_URL+"/slow" {
+			t.Errorf("aborted page is recorded: %+v", page)
+		}
+	}
+}
+
+func TestCrawlCancelledBeforeStart(t *testing.T) {
+	srv := testSite(t, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	levels, err := testCrawler(t, srv).Crawl(ctx, []string{TEST_SITE_URL + "/"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, expected %v", err, context.Canceled)
+	}
+	for _, level := range levels {
+		if len(level.CrawledPages) > 1 {
+			t.Errorf("level %d is crawled after cancellation: %s", level.LevelNum, levelPaths(level))
+		}
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("cancelled crawling took %v", time.Since(start))
+	}
+}
@@ -0,0 +1,218 @@
+package crawler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"golang.org/x/net/html"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+)
+
+// Kinds of duplicate groups
+const (
+	DUPLICATE_EXACT = "exact" // the same main text
+	DUPLICATE_NEAR  = "near"  // SimHash fingerprints differ in a few bits
+)
+
+const (
+	DEFAULT_SIMHASH_DISTANCE = 3 // differing bits of the near duplicates
+	SHINGLE_SIZE             = 3 // words in the features of the SimHash
+)
+
+// Tags not being the main text of the page: boilerplate and not visible content
+var CONTENT_SKIP_TAGS = []string{"head", "script", "style", "noscript", "template", "svg", "nav", "header",
+	"footer", "aside"}
+
+// Pages with the same or nearly the same main text
+type DuplicateGroup struct {
+	Kind string   `json:"kind"` // one of DUPLICATE_*
+	Urls []string `json:"urls"` // final urls, the first crawled one goes first
+}
+
+// Hashes the main text of the page: the exact hash and the SimHash fingerprint
+type ContentExtractor struct{}
+
+func (ContentExtractor) Name() string { return "content" }
+
+func (ContentExtractor) Start(doc *Document) PageExtraction { return &contentExtraction{} }
+
+type contentExtraction struct {
+	skip  int // depth of the skipped tags
+	words []string
+}
+
+func (e *contentExtraction) Token(token html.Token) {
+	switch token.Type {
+	case html.StartTagToken:
+		if contains(CONTENT_SKIP_TAGS, token.Data) {
+			e.skip++
+		}
+	case html.EndTagToken:
+		if e.skip > 0 && contains(CONTENT_SKIP_TAGS, token.Data) {
+			e.skip--
+		}
+	case html.TextToken:
+		if e.skip == 0 {
+			e.words = append(e.words, strings.Fields(strings.ToLower(token.Data))...)
+		}
+	}
+}
+
+func (e *contentExtraction) Finish(page *CrawledPage) (any, error) {
+	if len(e.words) == 0 {
+		return nil, nil
+	}
+	sum := sha1.Sum([]byte(strings.Join(e.words, " ")))
+	page.ContentHash = hex.EncodeToString(sum[:])
+	page.SimHash = simHash(e.words)
+	return nil, nil
+}
+
+// 64 bit SimHash of the word shingles
+func simHash(words []string) uint64 {
+	var weights [64]int
+	shingles := len(words) - SHINGLE_SIZE + 1
+	if shingles < 1 {
+		shingles = 1
+	}
+	for i := 0; i < shingles; i++ {
+		end := i + SHINGLE_SIZE
+		if end > len(words) {
+			end = len(words)
+		}
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strings.Join(words[i:end], " ")))
+		feature := mix64(h.Sum64())
+		for bit := 0; bit < 64; bit++ {
+			if feature&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+	return fingerprint
+}
+
+// Spreads the bits of FNV hash of the similar strings, murmur3 finalizer
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Returns groups of the crawled pages with the same main text and with SimHash fingerprints
+// differing in maxDistance bits at most, near duplicates aren't searched if maxDistance isn't positive.
+// Pages redirected to the same url are counted once, failed pages and resources are skipped
+func FindDuplicates(levels []CrawledLevel, maxDistance int) []DuplicateGroup {
+	// Pages by their contents
+	type content struct {
+		simHash uint64
+		urls    []string
+	}
+	contents := make([]*content, 0)
+	byHash := make(map[string]*content)
+	seenPages := make(map[string]struct{})
+	for _, lvl := range levels {
+		for _, page := range lvl.CrawledPages {
+			if _, ok := seenPages[page.FinalUrl]; ok || page.IsFailed() || page.ContentHash == "" {
+				continue
+			}
+			seenPages[page.FinalUrl] = struct{}{}
+
+			c, ok := byHash[page.ContentHash]
+			if !ok {
+				c = &content{simHash: page.SimHash}
+				byHash[page.ContentHash] = c
+				contents = append(contents, c)
+			}
+			c.urls = append(c.urls, page.FinalUrl)
+		}
+	}
+
+	// Near duplicates: at least one of maxDistance+1 bands of the close fingerprints is the same
+	parents := make([]int, len(contents))
+	for i := range parents {
+		parents[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parents[i] != i {
+			parents[i] = root(parents[i])
+		}
+		return parents[i]
+	}
+	if maxDistance > 0 && maxDistance < 64 {
+		bands := maxDistance + 1
+		bandBits := 64 / bands
+		for band := 0; band < bands; band++ {
+			shift := uint(band * bandBits)
+			mask := uint64(1)<<uint(bandBits) - 1
+			if band == bands-1 {
+				mask = ^uint64(0) >> shift
+			}
+			buckets := make(map[uint64][]int)
+			for i, c := range contents {
+				key := (c.simHash >> shift) & mask
+				for _, j := range buckets[key] {
+					if bits.OnesCount64(c.simHash^contents[j].simHash) <= maxDistance {
+						parents[root(i)] = root(j)
+					}
+				}
+				buckets[key] = append(buckets[key], i)
+			}
+		}
+	}
+
+	// Groups in the order of the crawling
+	groupsByRoot := make(map[int]int)
+	groups := make([]DuplicateGroup, 0)
+	for i, c := range contents {
+		r := root(i)
+		g, ok := groupsByRoot[r]
+		if !ok {
+			g = len(groups)
+			groupsByRoot[r] = g
+			groups = append(groups, DuplicateGroup{Kind: DUPLICATE_EXACT})
+		} else {
+			groups[g].Kind = DUPLICATE_NEAR
+		}
+		groups[g].Urls = append(groups[g].Urls, c.urls...)
+	}
+
+	duplicates := make([]DuplicateGroup, 0)
+	for _, group := range groups {
+		if len(group.Urls) > 1 {
+			duplicates = append(duplicates, group)
+		}
+	}
+	return duplicates
+}
+
+// Works like ExtractUniqueLinks, but only the first crawled page of the duplicates is kept
+func (c *Crawler) ExtractDistinctContentLinks(levels []CrawledLevel, maxDistance int) (distinctLinks []string) {
+	duplicates := make(map[string]struct{})
+	for _, group := range FindDuplicates(levels, maxDistance) {
+		for _, link := range group.Urls[1:] {
+			duplicates[c.normalize(link)] = struct{}{}
+		}
+	}
+
+	for _, link := range c.ExtractUniqueLinks(levels) {
+		if _, ok := duplicates[link]; !ok {
+			distinctLinks = append(distinctLinks, link)
+		}
+	}
+	return distinctLinks
+}
@@ -0,0 +1,100 @@
+package crawler
+
+import (
+	"context"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Article of n distinct words
+func article(seed int, n int) []string {
+	words := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		words = append(words, "w"+strconv.Itoa(seed)+"x"+strconv.Itoa(i*7919%n))
+	}
+	return words
+}
+
+func TestFindDuplicates(t *testing.T) {
+	text := article(1, 300)
+	edited := append([]string(nil), text...)
+	edited[150] = "edited"
+	other := article(2, 300)
+
+	page := func(nav string, words []string) string {
+		return "<html><head><title>" + nav + "</title></head><body><nav><a href=\"/" + nav + "\">" + nav +
+			"</a></nav><main><p>" + strings.Join(words, " ") + "</p></main>" +
+			"<script>var session = '" + nav + "';</script></body></html>"
+	}
+	pages := map[string]string{
+		"/article":       page("menu", text),
+		"/print":         page("print", text), // differs in boilerplate only
+		"/article-fixed": page("menu", edited),
+		"/other":         page("menu", other),
+		"/empty":         "<html><body><nav>menu</nav></body></html>",
+		"/empty-too":     "<html><body><footer>footer</footer></body></html>",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	c := &Crawler{}
+	crawled := make(map[string]CrawledPage)
+	level := CrawledLevel{}
+	for _, path := range []string{"/article", "/print", "/article-fixed", "/other", "/empty", "/empty-too"} {
+		p, err := c.ParsePage(context.Background(), srv.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crawled[path] = p
+		level.CrawledPages = append(level.CrawledPages, p)
+	}
+	levels := []CrawledLevel{level}
+
+	if crawled["/article"].ContentHash != crawled["/print"].ContentHash {
+		t.Errorf("boilerplate changes the content hash")
+	}
+	if crawled["/empty"].ContentHash != "" {
+		t.Errorf("page without main text is hashed: %q", crawled["/empty"].ContentHash)
+	}
+	distance := func(a string, b string) int {
+		return bits.OnesCount64(crawled[a].SimHash ^ crawled[b].SimHash)
+	}
+	if d := distance("/article", "/article-fixed"); d > DEFAULT_SIMHASH_DISTANCE {
+		t.Errorf("edited article distance = %d", d)
+	}
+	if d := distance("/article", "/other"); d <= DEFAULT_SIMHASH_DISTANCE {
+		t.Errorf("other article distance = %d", d)
+	}
+
+	exact := FindDuplicates(levels, 0)
+	if len(exact) != 1 || exact[0].Kind != DUPLICATE_EXACT ||
+		strings.Join(exact[0].Urls, " ") != srv.URL+"/article "+srv.URL+"/print" {
+		t.Errorf("exact duplicates = %+v", exact)
+	}
+
+	near := FindDuplicates(levels, DEFAULT_SIMHASH_DISTANCE)
+	if len(near) != 1 || near[0].Kind != DUPLICATE_NEAR || len(near[0].Urls) != 3 ||
+		near[0].Urls[0] != srv.URL+"/article" {
+		t.Errorf("near duplicates = %+v", near)
+	}
+
+	distinct := c.ExtractDistinctContentLinks(levels, DEFAULT_SIMHASH_DISTANCE)
+	sort.Strings(distinct)
+	expected := []string{srv.URL + "/article", srv.URL + "/empty", srv.URL + "/empty-too", srv.URL + "/other"}
+	if strings.Join(distinct, " ") != strings.Join(expected, " ") {
+		t.Errorf("distinct links = %v, expected %v", distinct, expected)
+	}
+}
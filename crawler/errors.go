@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+)
+
+// Kinds of page failures
+const (
+	ERR_KIND_DNS        = "dns"
+	ERR_KIND_TIMEOUT    = "timeout"
+	ERR_KIND_CONNECTION = "connection"
+	ERR_KIND_TLS        = "tls"
+	ERR_KIND_STATUS     = "status" // not 200 status code
+	ERR_KIND_PARSE      = "parse"
+	ERR_KIND_CANCELLED  = "cancelled"
+	ERR_KIND_REQUEST    = "request" // any other failure
+
+	ERR_KIND_REDIRECT_LOOP      = "redirectLoop"
+	ERR_KIND_TOO_MANY_REDIRECTS = "tooManyRedirects"
+)
+
+// Returns ERR_KIND_* of the request error
+func classifyError(ctx context.Context, err error) string {
+	if ctx.Err() != nil {
+		return ERR_KIND_CANCELLED
+	}
+
+	if errors.Is(err, ErrRedirectLoop) {
+		return ERR_KIND_REDIRECT_LOOP
+	}
+	if errors.Is(err, ErrTooManyRedirects) {
+		return ERR_KIND_TOO_MANY_REDIRECTS
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ERR_KIND_DNS
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ERR_KIND_TIMEOUT
+	}
+
+	var unknownAuthErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+	var certVerificationErr *tls.CertificateVerificationError
+	if errors.As(err, &unknownAuthErr) || errors.As(err, &certInvalidErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &recordHeaderErr) || errors.As(err, &certVerificationErr) {
+		return ERR_KIND_TLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) || isRetryableError(err) {
+		return ERR_KIND_CONNECTION
+	}
+
+	return ERR_KIND_REQUEST
+}
+
+// Reader which counts read bytes
+type countingReader struct {
+	Reader io.Reader
+	Count  int64
+}
+
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.Reader.Read(p)
+	r.Count += int64(n)
+	return n, err
+}
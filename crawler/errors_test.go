@@ -0,0 +1,69 @@
+package crawler
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+// Error as it's returned by http.Client
+func urlError(err error) error {
+	return &url.Error{Op: "Get", URL: "https://example.com/", Err: err}
+}
+
+func TestClassifyError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		kind string
+	}{
+		{"cancelled", cancelled, urlError(context.Canceled), ERR_KIND_CANCELLED},
+		{"redirect loop", context.Background(), urlError(fmt.Errorf("%w: /a", ErrRedirectLoop)),
+			ERR_KIND_REDIRECT_LOOP},
+		{"too many redirects", context.Background(), urlError(ErrTooManyRedirects), ERR_KIND_TOO_MANY_REDIRECTS},
+		{"dns", context.Background(), urlError(&net.OpError{Op: "dial", Net: "tcp",
+			Err: &net.DNSError{Err: "no such host", Name: "example.invalid"}}), ERR_KIND_DNS},
+		{"timeout", context.Background(), urlError(timeoutError{}), ERR_KIND_TIMEOUT},
+		{"tls", context.Background(), urlError(x509.UnknownAuthorityError{}), ERR_KIND_TLS},
+		{"hostname", context.Background(), urlError(x509.HostnameError{Host: "example.com"}), ERR_KIND_TLS},
+		{"connection refused", context.Background(), urlError(&net.OpError{Op: "dial", Net: "tcp",
+			Err: syscall.ECONNREFUSED}), ERR_KIND_CONNECTION},
+		{"connection reset", context.Background(), urlError(syscall.ECONNRESET), ERR_KIND_CONNECTION},
+		{"unexpected eof", context.Background(), urlError(io.ErrUnexpectedEOF), ERR_KIND_CONNECTION},
+		{"other", context.Background(), urlError(errors.New("unsupported protocol scheme")), ERR_KIND_REQUEST},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if kind := classifyError(tt.ctx, tt.err); kind != tt.kind {
+				t.Errorf("classifyError(%v) = %q, expected %q", tt.err, kind, tt.kind)
+			}
+		})
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	r := &countingReader{Reader: io.LimitReader(zeroReader{}, 1000)}
+	n, err := io.Copy(io.Discard, r)
+	if err != nil || n != 1000 || r.Count != 1000 {
+		t.Errorf("copied %d, counted %d, err %v", n, r.Count, err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,429 @@
+package crawler
+
+import (
+	"bytes"
+	"errors"
+	"golang.org/x/net/html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const MAX_PAGE_SIZE = 10 * 1024 * 1024 // html bytes to read, the rest of the page is ignored
+
+// Page the extractors run on
+type Document struct {
+	Url      *url.URL       // url after redirects
+	Response *http.Response // the body is being read by the extraction, nil if the html doesn't come from a response
+	Base     *url.URL       // url relative links are resolved against(see pageBaseUrl), known when the tokens are over
+
+	source  *bytes.Buffer // html source, recorded only if the DOM is used, see DomPageExtraction
+	node    *html.Node
+	nodeErr error
+}
+
+// Returns the DOM of the page. It's built once on the first call and shared by all the extractions,
+// the source is recorded only if a DomPageExtraction uses the DOM, so call it in Finish of such extractions
+func (d *Document) Node() (*html.Node, error) {
+	if d.source == nil {
+		return nil, errors.New("DOM of the page is not used by the extractions")
+	}
+	if d.node == nil && d.nodeErr == nil {
+		d.node, d.nodeErr = html.Parse(bytes.NewReader(d.source.Bytes()))
+	}
+	return d.node, d.nodeErr
+}
+
+// Returns the html source the DOM is built from, nil if the DOM is not used
+func (d *Document) Source() []byte {
+	if d.source == nil {
+		return nil
+	}
+	return d.source.Bytes()
+}
+
+// Extracts named values from a page reading its html tokens in a single pass.
+// The tokens are streamed without building the DOM, which is the cheapest way for the most of the values.
+// Extractions needing selectors over the tree implement DomPageExtraction instead of building their own DOM:
+// it costs the page source kept in memory and a single parsing per page shared by all of them
+type Extractor interface {
+	// Key of the values in CrawledPage.Extra
+	Name() string
+	// Returns the state of extraction from the single page, extractors are shared by the crawler workers
+	Start(doc *Document) PageExtraction
+}
+
+// Extraction from a single page
+type PageExtraction interface {
+	// Handles html tokens in the document order
+	Token(token html.Token)
+	// Called after the last token. Returns the values stored in CrawledPage.Extra under the extractor name,
+	// nil values aren't stored. Built-in extractions fill the fields of the page instead
+	Finish(page *CrawledPage) (any, error)
+}
+
+// Extraction that needs the html source, e.g. to build the DOM or to match the source with regexps
+type RawPageExtraction interface {
+	PageExtraction
+	// Handles the source bytes of the token before the token itself, the bytes are valid until the next call only
+	Raw(raw []byte)
+}
+
+// Extraction that queries the DOM of the page in Finish, see Document.Node
+type DomPageExtraction interface {
+	PageExtraction
+	// Checks if the extraction calls Document.Node, called once before the tokens
+	UsesDom() bool
+}
+
+// Extractors of the built-in CrawledPage fields, the order of the found links depends on their order
+func DefaultExtractors() []Extractor {
+	return []Extractor{
+		TitleExtractor{},
+		H1Extractor{},
+		LinksExtractor{},
+		HreflangExtractor{},
+		ImgsExtractor{},
+		CanonicalExtractor{},
+		SeoExtractor{},
+		AssetsExtractor{},
+		ContentExtractor{},
+	}
+}
+
+func (c *Crawler) extractors() []Extractor {
+	if c.Extractors == nil {
+		return DefaultExtractors()
+	}
+	return c.Extractors
+}
+
+// Runs the extractors over html tokens as they come, the DOM is built only if an extraction uses it
+func extractPage(r io.Reader, doc *Document, extractors []Extractor, page *CrawledPage) error {
+	extractions := make([]PageExtraction, len(extractors))
+	rawExtractions := make([]RawPageExtraction, 0)
+	for i, extractor := range extractors {
+		extractions[i] = extractor.Start(doc)
+		if rawExtraction, ok := extractions[i].(RawPageExtraction); ok {
+			rawExtractions = append(rawExtractions, rawExtraction)
+		}
+		if domExtraction, ok := extractions[i].(DomPageExtraction); ok && domExtraction.UsesDom() && doc.source == nil {
+			doc.source = &bytes.Buffer{}
+		}
+	}
+
+	baseSet := false
+	baseHref := ""
+
+	z := html.NewTokenizer(r)
+	for {
+		if z.Next() == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return z.Err()
+			}
+			break
+		}
+
+		// Before the token is read, as the tokenizer lowercases tag names in place
+		if doc.source != nil {
+			doc.source.Write(z.Raw())
+		}
+		for _, rawExtraction := range rawExtractions {
+			rawExtraction.Raw(z.Raw())
+		}
+
+		token := z.Token()
+		if token.Type == html.StartTagToken || token.Type == html.SelfClosingTagToken {
+			if href, ok := tokenAttr(token, "href"); ok && token.Data == "base" && !baseSet {
+				baseSet = true
+				baseHref = href
+			}
+		}
+
+		for _, extraction := range extractions {
+			extraction.Token(token)
+		}
+	}
+
+	// Links can't be resolved earlier as <base> may come after them
+	doc.Base = pageBaseUrl(baseHref, doc.Url)
+
+	for i, extraction := range extractions {
+		value, err := extraction.Finish(page)
+		if err != nil {
+			log.Print("[crawler]\tExtractor \"" + extractors[i].Name() + "\" failed on " + doc.Url.String() +
+				" with error: \"" + err.Error() + "\"")
+			continue
+		}
+		if value != nil {
+			if page.Extra == nil {
+				page.Extra = make(map[string]any)
+			}
+			page.Extra[extractors[i].Name()] = value
+		}
+	}
+
+	return nil
+}
+
+// Returns the value of the attribute, the first one wins if the attribute is duplicated
+func tokenAttr(token html.Token, key string) (string, bool) {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+func isStartTag(token html.Token, name string) bool {
+	return (token.Type == html.StartTagToken || token.Type == html.SelfClosingTagToken) && token.Data == name
+}
+
+func isHeading(name string) bool {
+	return len(name) == 2 && name[0] == 'h' && '1' <= name[1] && name[1] <= '6'
+}
+
+/* Built-in extractors */
+
+// Text of the first <title>
+type TitleExtractor struct{}
+
+func (TitleExtractor) Name() string { return "title" }
+
+func (TitleExtractor) Start(doc *Document) PageExtraction { return &titleExtraction{} }
+
+type titleExtraction struct {
+	inTitle bool
+	done    bool
+	title   strings.Builder
+}
+
+func (e *titleExtraction) Token(token html.Token) {
+	switch {
+	case e.done:
+	case isStartTag(token, "title"):
+		e.inTitle = true
+	case token.Type == html.EndTagToken && token.Data == "title" && e.inTitle:
+		e.inTitle, e.done = false, true
+	case token.Type == html.TextToken && e.inTitle:
+		e.title.WriteString(token.Data)
+	}
+}
+
+func (e *titleExtraction) Finish(page *CrawledPage) (any, error) {
+	page.Title = strings.TrimSpace(e.title.String())
+	return nil, nil
+}
+
+// Text of the first <h1> including the nested tags
+type H1Extractor struct{}
+
+func (H1Extractor) Name() string { return "h1" }
+
+func (H1Extractor) Start(doc *Document) PageExtraction { return &h1Extraction{} }
+
+type h1Extraction struct {
+	inH1 bool
+	done bool
+	h1   strings.Builder
+}
+
+func (e *h1Extraction) Token(token html.Token) {
+	switch {
+	case e.done:
+	case e.inH1 && token.Type != html.TextToken && isHeading(token.Data):
+		// Headings can't be nested, any heading closes the h1
+		e.inH1, e.done = false, true
+	case isStartTag(token, "h1"):
+		e.inH1 = true
+	case token.Type == html.TextToken && e.inH1:
+		e.h1.WriteString(token.Data)
+	}
+}
+
+func (e *h1Extraction) Finish(page *CrawledPage) (any, error) {
+	page.H1 = strings.TrimSpace(e.h1.String())
+	return nil, nil
+}
+
+// Resolved <a href> with anchor text, rel and region of the page
+type LinksExtractor struct{}
+
+func (LinksExtractor) Name() string { return "links" }
+
+func (LinksExtractor) Start(doc *Document) PageExtraction { return &linksExtraction{doc: doc} }
+
+type linksExtraction struct {
+	doc     *Document
+	links   []LinkInfo // with raw urls
+	inLink  bool       // text belongs to the last link
+	text    strings.Builder
+	regions []string // open nav, header and footer tags
+}
+
+func (e *linksExtraction) Token(token html.Token) {
+	switch token.Type {
+	case html.TextToken:
+		if e.inLink {
+			e.text.WriteString(token.Data)
+		}
+	case html.StartTagToken, html.SelfClosingTagToken:
+		switch token.Data {
+		case "a":
+			e.closeLink() // links can't be nested
+			if href, ok := tokenAttr(token, "href"); ok {
+				rel, _ := tokenAttr(token, "rel")
+				e.links = append(e.links, LinkInfo{Url: href, Rel: strings.Fields(strings.ToLower(rel)), Region: e.region()})
+				e.inLink = token.Type == html.StartTagToken
+			}
+		case LINK_REGION_NAV, LINK_REGION_HEADER, LINK_REGION_FOOTER:
+			if token.Type == html.StartTagToken {
+				e.regions = append(e.regions, token.Data)
+			}
+		}
+	case html.EndTagToken:
+		switch token.Data {
+		case "a":
+			e.closeLink()
+		case LINK_REGION_NAV, LINK_REGION_HEADER, LINK_REGION_FOOTER:
+			for i := len(e.regions) - 1; i >= 0; i-- {
+				if e.regions[i] == token.Data {
+					e.regions = e.regions[:i]
+					break
+				}
+			}
+		}
+	}
+}
+
+// Innermost open region
+func (e *linksExtraction) region() string {
+	if len(e.regions) == 0 {
+		return ""
+	}
+	return e.regions[len(e.regions)-1]
+}
+
+func (e *linksExtraction) closeLink() {
+	if e.inLink {
+		e.links[len(e.links)-1].Text = strings.Join(strings.Fields(e.text.String()), " ")
+	}
+	e.inLink = false
+	e.text.Reset()
+}
+
+func (e *linksExtraction) Finish(page *CrawledPage) (any, error) {
+	e.closeLink()
+	for _, link := range e.links {
+		extendedLink, err := ResolveLink(e.doc.Base, link.Url)
+		if err != nil {
+			continue
+		}
+		link.Url = extendedLink
+		link.Internal = isInternalLink(e.doc.Url, extendedLink)
+		link.NoFollow = hasNoFollowRel(link.Rel)
+		page.Links = append(page.Links, extendedLink)
+		page.LinksInfo = append(page.LinksInfo, link)
+	}
+	return nil, nil
+}
+
+// <link rel=alternate hreflang>, the urls are added to the links
+type HreflangExtractor struct{}
+
+func (HreflangExtractor) Name() string { return "hreflang" }
+
+func (HreflangExtractor) Start(doc *Document) PageExtraction { return &hreflangExtraction{doc: doc} }
+
+type hreflangExtraction struct {
+	doc       *Document
+	hreflangs [][2]string // raw hreflang, href
+}
+
+func (e *hreflangExtraction) Token(token html.Token) {
+	if !isStartTag(token, "link") {
+		return
+	}
+	if rel, _ := tokenAttr(token, "rel"); !strings.Contains(rel, "alternate") {
+		return
+	}
+
+	hreflang, hasHreflang := tokenAttr(token, "hreflang")
+	href, hasHref := tokenAttr(token, "href")
+	if hasHreflang && hasHref {
+		e.hreflangs = append(e.hreflangs, [2]string{strings.TrimSpace(hreflang), href})
+	}
+}
+
+func (e *hreflangExtraction) Finish(page *CrawledPage) (any, error) {
+	for _, hreflang := range e.hreflangs {
+		href, err := ResolveLink(e.doc.Base, hreflang[1])
+		if err != nil {
+			continue
+		}
+		page.HreflangUrlMap[hreflang[0]] = href
+		page.Links = append(page.Links, href)
+	}
+	return nil, nil
+}
+
+// Raw <img src>
+type ImgsExtractor struct{}
+
+func (ImgsExtractor) Name() string { return "imgs" }
+
+func (ImgsExtractor) Start(doc *Document) PageExtraction { return &imgsExtraction{} }
+
+type imgsExtraction struct {
+	imgs []string
+}
+
+func (e *imgsExtraction) Token(token html.Token) {
+	if isStartTag(token, "img") {
+		if src, ok := tokenAttr(token, "src"); ok {
+			e.imgs = append(e.imgs, strings.TrimSpace(src))
+		}
+	}
+}
+
+func (e *imgsExtraction) Finish(page *CrawledPage) (any, error) {
+	page.Imgs = append(page.Imgs, e.imgs...)
+	return nil, nil
+}
+
+// Href of the first <link rel=canonical>, the url is added to the links
+type CanonicalExtractor struct{}
+
+func (CanonicalExtractor) Name() string { return "canonical" }
+
+func (CanonicalExtractor) Start(doc *Document) PageExtraction { return &canonicalExtraction{doc: doc} }
+
+type canonicalExtraction struct {
+	doc       *Document
+	found     bool
+	canonical string // raw href
+}
+
+func (e *canonicalExtraction) Token(token html.Token) {
+	if e.found || !isStartTag(token, "link") {
+		return
+	}
+	if rel, _ := tokenAttr(token, "rel"); strings.Contains(rel, "canonical") {
+		e.found = true
+		e.canonical, _ = tokenAttr(token, "href")
+	}
+}
+
+func (e *canonicalExtraction) Finish(page *CrawledPage) (any, error) {
+	if e.canonical == "" {
+		return nil, nil
+	}
+	if canonicalUrl, err := ResolveLink(e.doc.Base, e.canonical); err == nil {
+		page.CanonicalUrl = canonicalUrl
+		page.Links = append(page.Links, canonicalUrl)
+	}
+	return nil, nil
+}
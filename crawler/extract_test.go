@@ -0,0 +1,271 @@
+package crawler
+
+import (
+	"bytes"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The former goquery DOM based extraction, kept as the reference for extractPage
+func extractPageGoquery(r io.Reader, pageUrl *url.URL, page *CrawledPage) error {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return err
+	}
+
+	baseHref, _ := doc.Find("base[href]").Eq(0).Attr("href")
+	base := pageBaseUrl(baseHref, pageUrl)
+
+	page.Title = strings.TrimSpace(doc.Find("title").Eq(0).Text())
+	page.H1 = strings.TrimSpace(doc.Find("h1").Eq(0).Text())
+
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		if link, exists := s.Attr("href"); exists {
+			if extendedLink, err := ResolveLink(base, link); err == nil {
+				page.Links = append(page.Links, extendedLink)
+			}
+		}
+	})
+
+	doc.Find("link[rel *= 'alternate']").Each(func(i int, s *goquery.Selection) {
+		hreflang, exists := s.Attr("hreflang")
+		if !exists {
+			return
+		}
+		href, exists := s.Attr("href")
+		if !exists {
+			return
+		}
+		href, err := ResolveLink(base, href)
+		if err != nil {
+			return
+		}
+		page.HreflangUrlMap[strings.TrimSpace(hreflang)] = href
+		page.Links = append(page.Links, href)
+	})
+
+	doc.Find("img").Each(func(i int, s *goquery.Selection) {
+		if imgSrc, exists := s.Attr("src"); exists {
+			page.Imgs = append(page.Imgs, strings.TrimSpace(imgSrc))
+		}
+	})
+
+	if canonicalUrl, exists := doc.Find("link[rel *= 'canonical']").Eq(0).Attr("href"); exists {
+		if canonicalUrl, err = ResolveLink(base, canonicalUrl); err == nil {
+			page.CanonicalUrl = canonicalUrl
+			page.Links = append(page.Links, canonicalUrl)
+		}
+	}
+
+	if _, exists := doc.Find("meta[content *= 'noindex']").Eq(0).Attr("content"); exists {
+		page.NoIndex = true
+	}
+
+	return nil
+}
+
+const testPageHtml = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title> Test &amp; page </title>
+	<meta name="robots" content="noindex, follow">
+	<link rel="canonical" href="/canonical/">
+	<link rel="canonical" href="/second-canonical/">
+	<link rel="alternate" hreflang="uk" href="https://domain.com/uk/">
+	<link rel="alternate" hreflang=" de " href="/de/">
+	<link rel="alternate" href="/no-hreflang/">
+	<script>var html = "<a href='/not-a-link'>";</script>
+	<style>a[href] { color: red; }</style>
+</head>
+<body>
+	<header><a href="/">Home</a><a href="mailto:info@domain.com">Mail</a></header>
+	<h1>First <span>heading</span></h1>
+	<h1>Second heading</h1>
+	<p>Text <a href="page?id=1&amp;b=2">query</a> <a href=" relative/ ">spaces</a> <a>no href</a></p>
+	<img src=" /img/a.png "><img src=""><img alt="no src">
+	<noscript><a href="/noscript/">noscript</a></noscript>
+	<a href="//other.com/path">other</a>
+	<a href="javascript:void(0)">js</a>
+</body>
+</html>`
+
+func newTestPage() CrawledPage {
+	return CrawledPage{Links: make([]string, 0), HreflangUrlMap: make(map[string]string), Imgs: make([]string, 0)}
+}
+
+func TestExtractPageMatchesGoquery(t *testing.T) {
+	pageUrl, _ := url.Parse("https://domain.com/one/two")
+
+	tests := []struct {
+		name string
+		html string
+	}{
+		{"full page", testPageHtml},
+		{"empty", ""},
+		{"no head", `<a href="x">x</a><h1>h<h2>h2</h2></h1><title>late title</title>`},
+		{"base", `<base href="/base/"><base href="/second/"><a href="x"></a><link rel="canonical">`},
+		{"unclosed", `<title>unclosed <h1>not closed <a href="/a">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := newTestPage()
+			if err := extractPageGoquery(strings.NewReader(tt.html), pageUrl, &expected); err != nil {
+				t.Fatal(err)
+			}
+			actual := newTestPage()
+			if err := extractPage(strings.NewReader(tt.html), &Document{Url: pageUrl}, DefaultExtractors(), &actual); err != nil {
+				t.Fatal(err)
+			}
+			actual.Seo, actual.LinksInfo, actual.Assets = SeoMeta{}, nil, nil // not extracted by the former code
+			actual.ContentHash, actual.SimHash = "", 0
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("extractPage = %+v\nexpected %+v", actual, expected)
+			}
+		})
+	}
+}
+
+// Big page with a lot of links
+func benchmarkPageHtml() []byte {
+	var b bytes.Buffer
+	b.WriteString(testPageHtml[:strings.Index(testPageHtml, "</body>")])
+	for i := 0; i < 5000; i++ {
+		n := strconv.Itoa(i)
+		b.WriteString(`<div class="item"><a href="/item/` + n + `/">Item ` + n + `</a><img src="/img/` + n +
+			`.png"><p>Lorem ipsum dolor sit amet, consectetur adipiscing elit.</p></div>` + "\n")
+	}
+	b.WriteString("</body></html>")
+	return b.Bytes()
+}
+
+func BenchmarkExtractPage(b *testing.B) {
+	benchmarkExtract(b, func(r io.Reader, pageUrl *url.URL, page *CrawledPage) error {
+		return extractPage(r, &Document{Url: pageUrl}, DefaultExtractors(), page)
+	})
+}
+
+func BenchmarkExtractPageGoquery(b *testing.B) {
+	benchmarkExtract(b, extractPageGoquery)
+}
+
+func benchmarkExtract(b *testing.B, extract func(io.Reader, *url.URL, *CrawledPage) error) {
+	pageUrl, _ := url.Parse("https://domain.com/one/two")
+	html := benchmarkPageHtml()
+	b.SetBytes(int64(len(html)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		page := newTestPage()
+		if err := extract(bytes.NewReader(html), pageUrl, &page); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Counts the tags of the page
+type tagsCountExtractor struct{}
+
+func (tagsCountExtractor) Name() string { return "tagsCount" }
+
+func (tagsCountExtractor) Start(doc *Document) PageExtraction { return &tagsCountExtraction{} }
+
+type tagsCountExtraction struct {
+	count map[string]int
+}
+
+func (e *tagsCountExtraction) Token(token html.Token) {
+	if token.Type == html.StartTagToken || token.Type == html.SelfClosingTagToken {
+		if e.count == nil {
+			e.count = make(map[string]int)
+		}
+		e.count[token.Data]++
+	}
+}
+
+func (e *tagsCountExtraction) Finish(page *CrawledPage) (any, error) {
+	if e.count == nil {
+		return nil, nil
+	}
+	return e.count, nil
+}
+
+func TestCustomExtractor(t *testing.T) {
+	pageUrl, _ := url.Parse("https://domain.com/")
+
+	page := newTestPage()
+	extractors := append(DefaultExtractors(), tagsCountExtractor{})
+	html := `<title>t</title><a href="/a"></a><a href="/b"></a>`
+	if err := extractPage(strings.NewReader(html), &Document{Url: pageUrl}, extractors, &page); err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Title != "t" || len(page.Links) != 2 {
+		t.Errorf("built-in fields aren't extracted: %+v", page)
+	}
+	count, ok := page.Extra["tagsCount"].(map[string]int)
+	if !ok || count["a"] != 2 || count["title"] != 1 {
+		t.Errorf("Extra = %v, expected the tags count", page.Extra)
+	}
+
+	page = newTestPage()
+	if err := extractPage(strings.NewReader(""), &Document{Url: pageUrl}, extractors, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Extra != nil {
+		t.Errorf("Extra = %v, expected nil values to be skipped", page.Extra)
+	}
+}
+
+// Returns the DOM it's given by the document
+type domExtractor struct{ name string }
+
+func (e domExtractor) Name() string { return e.name }
+
+func (e domExtractor) Start(doc *Document) PageExtraction { return &domExtraction{doc: doc} }
+
+type domExtraction struct {
+	doc *Document
+}
+
+func (e *domExtraction) UsesDom() bool { return true }
+
+func (e *domExtraction) Token(token html.Token) {}
+
+func (e *domExtraction) Finish(page *CrawledPage) (any, error) { return e.doc.Node() }
+
+func TestSharedDom(t *testing.T) {
+	pageUrl, _ := url.Parse("https://domain.com/")
+	source := `<title>t</title><P>Text</P>`
+
+	page := newTestPage()
+	doc := &Document{Url: pageUrl}
+	extractors := append(DefaultExtractors(), domExtractor{"first"}, domExtractor{"second"})
+	if err := extractPage(strings.NewReader(source), doc, extractors, &page); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := page.Extra["first"].(*html.Node)
+	if first == nil || first != page.Extra["second"] {
+		t.Errorf("Extra = %v, expected the same DOM", page.Extra)
+	}
+	if string(doc.Source()) != source {
+		t.Errorf("source = %q, expected %q", doc.Source(), source)
+	}
+
+	doc = &Document{Url: pageUrl}
+	if err := extractPage(strings.NewReader(source), doc, DefaultExtractors(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if node, err := doc.Node(); node != nil || err == nil || doc.Source() != nil {
+		t.Errorf("DOM is built without extractions using it")
+	}
+}
@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	DEFAULT_USER_AGENT      = "Mozilla/5.0 (compatible; go-crawler/1.0)"
+	DEFAULT_REQUEST_TIMEOUT = 15 * time.Second
+	DEFAULT_TOTAL_TIMEOUT   = 60 * time.Second
+)
+
+// Settings of the http client used to fetch pages and sitemaps
+type FetcherConfig struct {
+	RequestTimeout     time.Duration     // connecting + waiting for the response headers, 0 - no timeout
+	TotalTimeout       time.Duration     // whole request including redirects and body reading, 0 - no timeout
+	UserAgent          string            // User-Agent header, DEFAULT_USER_AGENT if empty
+	Headers            map[string]string // extra headers sent with every request
+	Cookies            []*http.Cookie    // cookies sent with every request
+	ProxyUrl           string            // http(s)://[user:password@]host:port, environment proxy if empty
+	InsecureSkipVerify bool              // do not verify server certificates
+	RootCAsFile        string            // PEM file with additional trusted root certificates
+	MaxRedirects       int               // redirect hops to follow, DEFAULT_MAX_REDIRECTS if not positive
+}
+
+func DefaultFetcherConfig() FetcherConfig {
+	return FetcherConfig{
+		RequestTimeout: DEFAULT_REQUEST_TIMEOUT,
+		TotalTimeout:   DEFAULT_TOTAL_TIMEOUT,
+		UserAgent:      DEFAULT_USER_AGENT,
+		Headers:        make(map[string]string),
+		MaxRedirects:   DEFAULT_MAX_REDIRECTS,
+	}
+}
+
+// Performs http requests according to the FetcherConfig.
+// Safe for concurrent use
+type Fetcher struct {
+	config FetcherConfig
+	client *http.Client
+}
+
+// Fetcher with DefaultFetcherConfig, used when no fetcher is specified
+var DefaultFetcher, _ = NewFetcher(DefaultFetcherConfig())
+
+func NewFetcher(config FetcherConfig) (*Fetcher, error) {
+	if config.UserAgent == "" {
+		config.UserAgent = DEFAULT_USER_AGENT
+	}
+	if config.MaxRedirects <= 0 {
+		config.MaxRedirects = DEFAULT_MAX_REDIRECTS
+	}
+
+	// Proxy
+	proxy := http.ProxyFromEnvironment
+	if config.ProxyUrl != "" {
+		proxyUrl, err := url.Parse(config.ProxyUrl)
+		if err != nil {
+			return nil, errors.New("Failed to parse proxy url: \"" + config.ProxyUrl + "\" with error: \"" +
+				err.Error() + "\"")
+		}
+		if proxyUrl.Scheme != "http" && proxyUrl.Scheme != "https" {
+			return nil, errors.New("Not supported proxy scheme: \"" + config.ProxyUrl + "\"")
+		}
+		proxy = http.ProxyURL(proxyUrl)
+	}
+
+	// TLS
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}
+	if config.RootCAsFile != "" {
+		pem, err := ioutil.ReadFile(config.RootCAsFile)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("No certificates found in: \"" + config.RootCAsFile + "\"")
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   config.RequestTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.RequestTimeout,
+		ResponseHeaderTimeout: config.RequestTimeout,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &Fetcher{
+		config: config,
+		client: &http.Client{
+			Transport:     transport,
+			Timeout:       config.TotalTimeout,
+			CheckRedirect: checkRedirect(config.MaxRedirects),
+		},
+	}, nil
+}
+
+func (f *Fetcher) Config() FetcherConfig {
+	return f.config
+}
+
+// Performs GET request bound to the ctx with configured headers and cookies
+func (f *Fetcher) Get(ctx context.Context, url string) (*http.Response, error) {
+	return f.Do(ctx, http.MethodGet, url)
+}
+
+// Performs HEAD request bound to the ctx with configured headers and cookies
+func (f *Fetcher) Head(ctx context.Context, url string) (*http.Response, error) {
+	return f.Do(ctx, http.MethodHead, url)
+}
+
+// Performs the request without body bound to the ctx with configured headers and cookies.
+// Compressed response body is decoded, see ACCEPT_ENCODING
+func (f *Fetcher) Do(ctx context.Context, method string, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept-Encoding", ACCEPT_ENCODING)
+	for name, value := range f.config.Headers {
+		req.Header.Set(name, value)
+	}
+	for _, cookie := range f.config.Cookies {
+		req.AddCookie(cookie)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	decodeBody(resp)
+
+	return resp, nil
+}
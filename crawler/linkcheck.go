@@ -0,0 +1,211 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Type of checked external links, assets have ASSET_* types
+const LINK_TYPE_EXTERNAL = "external"
+
+// Result of checking the asset or external link
+type CheckedLink struct {
+	Url         string   `json:"url"`
+	Type        string   `json:"type"`                  // LINK_TYPE_EXTERNAL or one of ASSET_*
+	Method      string   `json:"method"`                // HEAD or GET if HEAD failed
+	StatusCode  int      `json:"statusCode"`            // 0 if no response
+	RedirectUrl string   `json:"redirectUrl,omitempty"` // url after redirects if it differs
+	ContentType string   `json:"contentType,omitempty"`
+	Size        int64    `json:"size"` // Content-Length, -1 if unknown
+	ErrorKind   string   `json:"errorKind,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Broken      bool     `json:"broken"`    // failed or answered with 4xx/5xx status
+	Referrers   []string `json:"referrers"` // pages the link is found on
+}
+
+// Broken asset or external link found on the page
+type BrokenResource struct {
+	PageUrl    string `json:"pageUrl"`
+	Url        string `json:"url"`
+	Type       string `json:"type"`
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error,omitempty"`
+}
+
+type LinkCheckReport struct {
+	CheckedNum int              `json:"checkedNum"`
+	BrokenNum  int              `json:"brokenNum"`
+	Links      []CheckedLink    `json:"links"`
+	Broken     []BrokenResource `json:"broken"` // by referring pages
+}
+
+// Returns unique assets and external links of the crawled pages with the pages they are found on
+func linksToCheck(levels []CrawledLevel) []CheckedLink {
+	byUrl := make(map[string]*CheckedLink)
+	links := make([]*CheckedLink, 0)
+	add := func(link string, linkType string, pageUrl string) {
+		checked, ok := byUrl[link]
+		if !ok {
+			checked = &CheckedLink{Url: link, Type: linkType}
+			byUrl[link] = checked
+			links = append(links, checked)
+		}
+		if n := len(checked.Referrers); n == 0 || checked.Referrers[n-1] != pageUrl {
+			checked.Referrers = append(checked.Referrers, pageUrl)
+		}
+	}
+
+	seenPages := make(map[string]struct{})
+	for _, lvl := range levels {
+		for _, page := range lvl.CrawledPages {
+			if _, ok := seenPages[page.FinalUrl]; ok || page.IsFailed() {
+				continue
+			}
+			seenPages[page.FinalUrl] = struct{}{}
+
+			for _, asset := range page.Assets {
+				add(asset.Url, asset.Type, page.FinalUrl)
+			}
+			for _, link := range page.LinksInfo {
+				if !link.Internal {
+					add(link.Url, LINK_TYPE_EXTERNAL, page.FinalUrl)
+				}
+			}
+		}
+	}
+
+	result := make([]CheckedLink, 0, len(links))
+	for _, link := range links {
+		result = append(result, *link)
+	}
+	return result
+}
+
+// Checks if assets and external links of the crawled pages resolve: HEAD request is made,
+// GET is made if HEAD fails or is answered with 4xx/5xx status. Redirects are followed.
+// Requests are made by politeness.Workers with per host limits of the politeness, robots.txt isn't consulted.
+// Returns the links checked so far and ctx.Err() if ctx is cancelled
+func (c *Crawler) CheckLinks(ctx context.Context, levels []CrawledLevel,
+	politeness PolitenessConfig) (LinkCheckReport, error) {
+	links := linksToCheck(levels)
+	log.Print("[crawler]\tChecking ", len(links), " assets and external links")
+
+	s := newScheduler(politeness)
+	tasks := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < politeness.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				c.checkLink(ctx, s, &links[i])
+			}
+		}()
+	}
+
+	checkedNum := 0
+feed:
+	for i := range links {
+		select {
+		case tasks <- i:
+			checkedNum++
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(tasks)
+	wg.Wait()
+
+	report := LinkCheckReport{Links: make([]CheckedLink, 0, checkedNum), Broken: make([]BrokenResource, 0)}
+	for _, link := range links[:checkedNum] {
+		if ctx.Err() != nil && link.ErrorKind == ERR_KIND_CANCELLED {
+			continue // aborted, it's unknown if the link is broken
+		}
+		report.Links = append(report.Links, link)
+		if !link.Broken {
+			continue
+		}
+		report.BrokenNum++
+		for _, pageUrl := range link.Referrers {
+			report.Broken = append(report.Broken, BrokenResource{
+				PageUrl:    pageUrl,
+				Url:        link.Url,
+				Type:       link.Type,
+				StatusCode: link.StatusCode,
+				Error:      link.Error,
+			})
+		}
+	}
+	report.CheckedNum = len(report.Links)
+	sort.SliceStable(report.Broken, func(i, j int) bool {
+		return report.Broken[i].PageUrl < report.Broken[j].PageUrl
+	})
+
+	log.Print("[crawler]\tChecked ", report.CheckedNum, " links, broken: ", report.BrokenNum)
+
+	return report, ctx.Err()
+}
+
+func (c *Crawler) checkLink(ctx context.Context, s *scheduler, link *CheckedLink) {
+	resp, err := c.request(ctx, s, http.MethodHead, link.Url)
+	link.Method = http.MethodHead
+	if err != nil || resp.StatusCode >= 400 {
+		// Some servers do not support HEAD
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		resp, err = c.request(ctx, s, http.MethodGet, link.Url)
+		link.Method = http.MethodGet
+	}
+
+	if err != nil {
+		link.ErrorKind = classifyError(ctx, err)
+		link.Error = err.Error()
+		link.Broken = true
+		return
+	}
+	defer resp.Body.Close() // the body of GET isn't downloaded
+
+	link.StatusCode = resp.StatusCode
+	link.ContentType = resp.Header.Get("Content-Type")
+	link.Size = resp.ContentLength
+	if final := resp.Request.URL.String(); final != link.Url {
+		link.RedirectUrl = final
+	}
+	if resp.StatusCode >= 400 {
+		link.ErrorKind = ERR_KIND_STATUS
+		link.Error = "Not 200 status code(" + strconv.Itoa(resp.StatusCode) + ")"
+		link.Broken = true
+	}
+}
+
+// Performs the request with respect to the per host limits of the scheduler
+func (c *Crawler) request(ctx context.Context, s *scheduler, method string, link string) (*http.Response, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errors.New("Not absolute url: \"" + link + "\"")
+	}
+
+	release, err := s.acquire(ctx, u.Host, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := c.fetcher().Do(ctx, method, link)
+	if err != nil {
+		return nil, err
+	}
+	s.observe(u.Host, resp)
+
+	return resp, nil
+}
@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/moved":
+			http.Redirect(w, r, "/ok.png", http.StatusMovedPermanently)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	levels := []CrawledLevel{{CrawledPages: []CrawledPage{
+		{FinalUrl: "https://domain.com/", Assets: []Asset{
+			{srv.URL + "/ok.png", ASSET_IMAGE},
+			{srv.URL + "/missing.js", ASSET_SCRIPT},
+		}, LinksInfo: []LinkInfo{
+			{Url: "https://domain.com/internal/", Internal: true},
+			{Url: srv.URL + "/no-head"},
+			{Url: srv.URL + "/moved"},
+		}},
+		{FinalUrl: "https://domain.com/a/", Assets: []Asset{{srv.URL + "/missing.js", ASSET_SCRIPT}}},
+	}}}
+
+	c := &Crawler{}
+	politeness := DefaultPolitenessConfig()
+	politeness.RequestsPerSecond = 0
+	report, err := c.CheckLinks(context.Background(), levels, politeness)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.CheckedNum != 4 || report.BrokenNum != 1 {
+		t.Fatalf("checked %d, broken %d, expected 4 and 1: %+v", report.CheckedNum, report.BrokenNum, report.Links)
+	}
+	byUrl := make(map[string]CheckedLink)
+	for _, link := range report.Links {
+		byUrl[link.Url] = link
+	}
+
+	if link := byUrl[srv.URL+"/ok.png"]; link.Method != http.MethodHead || link.StatusCode != 200 ||
+		link.ContentType != "image/png" || link.Broken {
+		t.Errorf("ok.png = %+v", link)
+	}
+	if link := byUrl[srv.URL+"/no-head"]; link.Method != http.MethodGet || link.StatusCode != 200 ||
+		link.Type != LINK_TYPE_EXTERNAL || link.Broken {
+		t.Errorf("no-head = %+v", link)
+	}
+	if link := byUrl[srv.URL+"/moved"]; link.RedirectUrl != srv.URL+"/ok.png" || link.Broken {
+		t.Errorf("moved = %+v", link)
+	}
+
+	expected := []BrokenResource{
+		{PageUrl: "https://domain.com/", Url: srv.URL + "/missing.js", Type: ASSET_SCRIPT, StatusCode: 404,
+			Error: "Not 200 status code(404)"},
+		{PageUrl: "https://domain.com/a/", Url: srv.URL + "/missing.js", Type: ASSET_SCRIPT, StatusCode: 404,
+			Error: "Not 200 status code(404)"},
+	}
+	if len(report.Broken) != 2 || report.Broken[0] != expected[0] || report.Broken[1] != expected[1] {
+		t.Errorf("Broken = %+v, expected %+v", report.Broken, expected)
+	}
+}
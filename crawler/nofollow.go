@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Policies of nofollow links: rel nofollow, sponsored or ugc and the links of meta robots nofollow pages
+const (
+	NOFOLLOW_FOLLOW = "follow" // crawl them as any other link
+	NOFOLLOW_RECORD = "record" // keep them in the page links, but do not crawl
+	NOFOLLOW_IGNORE = "ignore" // drop them from the page links
+)
+
+// Regions of the page links are found in
+const (
+	LINK_REGION_NAV    = "nav"
+	LINK_REGION_HEADER = "header"
+	LINK_REGION_FOOTER = "footer"
+)
+
+// Rel values which ask not to follow the link
+var NOFOLLOW_RELS = []string{"nofollow", "sponsored", "ugc"}
+
+// Link found on the page
+type LinkInfo struct {
+	Url      string   `json:"url"`              // resolved url
+	Text     string   `json:"text"`             // anchor text with collapsed spaces
+	Rel      []string `json:"rel,omitempty"`    // lowercased rel values
+	Region   string   `json:"region,omitempty"` // innermost LINK_REGION_* the link is in
+	Internal bool     `json:"internal"`         // the host is the same as the page one(www. is ignored)
+	NoFollow bool     `json:"noFollow"`         // by rel or meta robots nofollow of the page
+}
+
+func hasNoFollowRel(rel []string) bool {
+	for _, r := range rel {
+		if contains(NOFOLLOW_RELS, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternalLink(pageUrl *url.URL, link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	trimWww := func(host string) string {
+		return strings.TrimPrefix(strings.ToLower(host), "www.")
+	}
+	return trimWww(u.Hostname()) == trimWww(pageUrl.Hostname())
+}
+
+// Unknown policies are treated as NOFOLLOW_FOLLOW
+func (c *Crawler) noFollowPolicy() string {
+	switch c.NoFollowPolicy {
+	case NOFOLLOW_RECORD, NOFOLLOW_IGNORE:
+		return c.NoFollowPolicy
+	}
+	return NOFOLLOW_FOLLOW
+}
+
+// Marks all the links of meta robots nofollow page as nofollow
+// and drops nofollow links from the page if they are ignored
+func (c *Crawler) applyNoFollowPolicy(page *CrawledPage) {
+	if page.Seo.Robots.NoFollow {
+		for i := range page.LinksInfo {
+			page.LinksInfo[i].NoFollow = true
+		}
+	}
+	if c.noFollowPolicy() != NOFOLLOW_IGNORE {
+		return
+	}
+
+	linksInfo := make([]LinkInfo, 0, len(page.LinksInfo))
+	for _, link := range page.LinksInfo {
+		if !link.NoFollow {
+			linksInfo = append(linksInfo, link)
+		}
+	}
+	page.Links = withoutNoFollowLinks(*page)
+	page.LinksInfo = linksInfo
+}
+
+// Returns the page links to crawl according to the nofollow policy
+func (c *Crawler) linksToFollow(page CrawledPage) []string {
+	if c.noFollowPolicy() == NOFOLLOW_FOLLOW {
+		return page.Links
+	}
+	return withoutNoFollowLinks(page)
+}
+
+// Returns the page links except of the ones which are nofollow in all the occurrences on the page
+func withoutNoFollowLinks(page CrawledPage) []string {
+	followed := make(map[string]struct{})
+	for _, link := range page.LinksInfo {
+		if !link.NoFollow {
+			followed[link.Url] = struct{}{}
+		}
+	}
+	noFollow := make(map[string]struct{})
+	for _, link := range page.LinksInfo {
+		if _, ok := followed[link.Url]; !ok && link.NoFollow {
+			noFollow[link.Url] = struct{}{}
+		}
+	}
+
+	links := make([]string, 0, len(page.Links))
+	for _, link := range page.Links {
+		if _, ok := noFollow[link]; !ok {
+			links = append(links, link)
+		}
+	}
+	return links
+}
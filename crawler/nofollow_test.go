@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLinksHtml = `<html><head><meta name="robots" content="%s"></head>
+<body>
+	<header><nav><a href="/">Home</a></nav><a href="/about/"> About
+		<b>us</b> </a></header>
+	<a href="/sponsored/" rel="Sponsored noopener">Ad</a>
+	<a href="/twice/" rel="nofollow">Twice</a>
+	<a href="https://www.domain.com/twice/">Twice</a>
+	<a href="https://other.com/" rel="ugc">Other</a>
+	<footer><a href="/contacts/">Contacts</footer>
+</body></html>`
+
+func extractTestLinks(t *testing.T, c *Crawler, robots string) CrawledPage {
+	pageUrl, _ := url.Parse("https://domain.com/")
+	page := newTestPage()
+	html := strings.Replace(testLinksHtml, "%s", robots, 1)
+	if err := extractPage(strings.NewReader(html), &Document{Url: pageUrl}, DefaultExtractors(), &page); err != nil {
+		t.Fatal(err)
+	}
+	c.applyNoFollowPolicy(&page)
+	return page
+}
+
+func TestLinksInfo(t *testing.T) {
+	page := extractTestLinks(t, &Crawler{}, "index")
+
+	expected := []LinkInfo{
+		{Url: "https://domain.com/", Text: "Home", Rel: []string{}, Region: LINK_REGION_NAV, Internal: true},
+		{Url: "https://domain.com/about/", Text: "About us", Rel: []string{}, Region: LINK_REGION_HEADER, Internal: true},
+		{Url: "https://domain.com/sponsored/", Text: "Ad", Rel: []string{"sponsored", "noopener"}, Internal: true,
+			NoFollow: true},
+		{Url: "https://domain.com/twice/", Text: "Twice", Rel: []string{"nofollow"}, Internal: true, NoFollow: true},
+		{Url: "https://www.domain.com/twice/", Text: "Twice", Rel: []string{}, Internal: true},
+		{Url: "https://other.com/", Text: "Other", Rel: []string{"ugc"}, NoFollow: true},
+		{Url: "https://domain.com/contacts/", Text: "Contacts", Rel: []string{}, Region: LINK_REGION_FOOTER,
+			Internal: true},
+	}
+	if !reflect.DeepEqual(page.LinksInfo, expected) {
+		t.Errorf("LinksInfo = %+v\nexpected %+v", page.LinksInfo, expected)
+	}
+}
+
+func TestNoFollowPolicy(t *testing.T) {
+	all := []string{"https://domain.com/", "https://domain.com/about/", "https://domain.com/sponsored/",
+		"https://domain.com/twice/", "https://www.domain.com/twice/", "https://other.com/", "https://domain.com/contacts/"}
+	followed := []string{"https://domain.com/", "https://domain.com/about/", "https://www.domain.com/twice/",
+		"https://domain.com/contacts/"}
+
+	tests := []struct {
+		name          string
+		policy        string
+		robots        string
+		expectedLinks []string
+		expectedNext  []string
+	}{
+		{"default", "", "index", all, all},
+		{"follow", NOFOLLOW_FOLLOW, "nofollow", all, all},
+		{"record", NOFOLLOW_RECORD, "index", all, followed},
+		{"ignore", NOFOLLOW_IGNORE, "index", followed, followed},
+		{"record nofollow page", NOFOLLOW_RECORD, "noindex, nofollow", all, []string{}},
+		{"ignore nofollow page", NOFOLLOW_IGNORE, "none", []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Crawler{NoFollowPolicy: tt.policy}
+			page := extractTestLinks(t, c, tt.robots)
+			if !reflect.DeepEqual(page.Links, tt.expectedLinks) {
+				t.Errorf("Links = %q, expected %q", page.Links, tt.expectedLinks)
+			}
+			if next := c.linksToFollow(page); !reflect.DeepEqual(next, tt.expectedNext) {
+				t.Errorf("linksToFollow = %q, expected %q", next, tt.expectedNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_WORKERS                = 4
+	DEFAULT_REQUESTS_PER_SECOND    = 4
+	DEFAULT_MAX_IN_FLIGHT_PER_HOST = 2
+	DEFAULT_BACKOFF                = 5 * time.Second
+	DEFAULT_MAX_BACKOFF            = 5 * time.Minute
+)
+
+// Per host scheduling settings of a crawling
+type PolitenessConfig struct {
+	Workers            int           // number of parallel workers, DEFAULT_WORKERS if not positive
+	RequestsPerSecond  float64       // token bucket rate per host, 0 - unlimited
+	Burst              int           // token bucket size per host, 1 if not positive
+	MaxInFlightPerHost int           // parallel requests per host, 0 - unlimited
+	Backoff            time.Duration // initial pause of a host answered 429/503 without Retry-After, doubled each time
+	MaxBackoff         time.Duration // the longest pause of a host, Retry-After included
+}
+
+func DefaultPolitenessConfig() PolitenessConfig {
+	return PolitenessConfig{
+		Workers:            DEFAULT_WORKERS,
+		RequestsPerSecond:  DEFAULT_REQUESTS_PER_SECOND,
+		Burst:              1,
+		MaxInFlightPerHost: DEFAULT_MAX_IN_FLIGHT_PER_HOST,
+		Backoff:            DEFAULT_BACKOFF,
+		MaxBackoff:         DEFAULT_MAX_BACKOFF,
+	}
+}
+
+func (pc PolitenessConfig) workers() int {
+	if pc.Workers <= 0 {
+		return DEFAULT_WORKERS
+	}
+	return pc.Workers
+}
+
+// Scheduling state of a single host
+type hostState struct {
+	slots        chan struct{} // in flight requests, nil - unlimited
+	tokens       float64
+	lastRefill   time.Time
+	blockedUntil time.Time     // the host asked to slow down
+	backoff      time.Duration // the next pause without Retry-After
+}
+
+// Per host token buckets and in flight limits
+type scheduler struct {
+	config PolitenessConfig
+	mu     sync.Mutex
+	hosts  map[string]*hostState
+}
+
+func newScheduler(config PolitenessConfig) *scheduler {
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = DEFAULT_BACKOFF
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DEFAULT_MAX_BACKOFF
+	}
+
+	return &scheduler{config: config, hosts: make(map[string]*hostState)}
+}
+
+func (s *scheduler) host(host string) *hostState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostState{tokens: float64(s.config.Burst), lastRefill: time.Now(), backoff: s.config.Backoff}
+		if s.config.MaxInFlightPerHost > 0 {
+			h.slots = make(chan struct{}, s.config.MaxInFlightPerHost)
+		}
+		s.hosts[host] = h
+	}
+
+	return h
+}
+
+// Requests per second for the host: the lowest of configured rate and robots.txt Crawl-delay
+func (s *scheduler) rate(crawlDelay time.Duration) float64 {
+	rate := s.config.RequestsPerSecond
+	if crawlDelay > 0 {
+		if delayRate := float64(time.Second) / float64(crawlDelay); rate <= 0 || delayRate < rate {
+			rate = delayRate
+		}
+	}
+	return rate
+}
+
+// Waits until a request to the host is allowed.
+// The returned release func must be called when the request is done
+func (s *scheduler) acquire(ctx context.Context, host string, crawlDelay time.Duration) (release func(), err error) {
+	h := s.host(host)
+
+	// Take in flight slot
+	release = func() {}
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+			release = func() { <-h.slots }
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// Take a token
+	rate := s.rate(crawlDelay)
+	for {
+		s.mu.Lock()
+		now := time.Now()
+		var wait time.Duration
+		if now.Before(h.blockedUntil) {
+			wait = h.blockedUntil.Sub(now)
+		} else if rate <= 0 {
+			s.mu.Unlock()
+			return release, nil
+		} else {
+			h.tokens += now.Sub(h.lastRefill).Seconds() * rate
+			if h.tokens > float64(s.config.Burst) {
+				h.tokens = float64(s.config.Burst)
+			}
+			h.lastRefill = now
+			if h.tokens >= 1 {
+				h.tokens--
+				s.mu.Unlock()
+				return release, nil
+			}
+			wait = time.Duration((1 - h.tokens) / rate * float64(time.Second))
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Updates the host state with the response: pauses the host on 429 and 503
+func (s *scheduler) observe(host string, resp *http.Response) {
+	h := s.host(host)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		h.backoff = s.config.Backoff
+		return
+	}
+
+	pause, ok := parseRetryAfter(resp.Header.Get("Retry-After"))
+	if !ok {
+		pause = h.backoff
+		h.backoff *= 2
+		if h.backoff > s.config.MaxBackoff {
+			h.backoff = s.config.MaxBackoff
+		}
+	}
+	if pause > s.config.MaxBackoff {
+		pause = s.config.MaxBackoff
+	}
+	if until := time.Now().Add(pause); until.After(h.blockedUntil) {
+		h.blockedUntil = until
+	}
+}
+
+// Parses Retry-After header: delay in seconds or http date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		pause := time.Until(date)
+		if pause < 0 {
+			pause = 0
+		}
+		return pause, true
+	}
+	return 0, false
+}
+
+func (c *Crawler) getScheduler() *scheduler {
+	c.schedulerOnce.Do(func() {
+		c.scheduler = newScheduler(c.Politeness)
+	})
+	return c.scheduler
+}
+
+// Performs GET request by the renderer with respect to the per host politeness of the crawler
+func (c *Crawler) get(ctx context.Context, link string, renderer Renderer) (*http.Response, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+
+	var crawlDelay time.Duration
+	if !c.IgnoreRobots && u.Path != ROBOTS_PATH {
+		crawlDelay = c.robotsFor(ctx, link).CrawlDelay(c.fetcher().Config().UserAgent)
+	}
+
+	s := c.getScheduler()
+	release, err := s.acquire(ctx, u.Host, crawlDelay)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := renderer.Render(ctx, link)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	s.observe(u.Host, resp)
+
+	// The request is in flight until the body is read and closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// Response body which frees the host in flight slot on close
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,142 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		pause time.Duration
+		ok    bool
+	}{
+		{"empty", "", 0, false},
+		{"seconds", "120", 2 * time.Minute, true},
+		{"zero seconds", "0", 0, true},
+		{"negative seconds", "-5", 0, false},
+		{"past date", "Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+		{"garbage", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pause, ok := parseRetryAfter(tt.value)
+			if pause != tt.pause || ok != tt.ok {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, expected %v, %v", tt.value, pause, ok, tt.pause, tt.ok)
+			}
+		})
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if pause, ok := parseRetryAfter(date); !ok || pause < 59*time.Minute || pause > time.Hour {
+		t.Errorf("parseRetryAfter(%q) = %v, %v", date, pause, ok)
+	}
+}
+
+func TestSchedulerRate(t *testing.T) {
+	s := newScheduler(PolitenessConfig{RequestsPerSecond: 20})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		release, err := s.acquire(context.Background(), "example.com", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	// The first request takes the burst token, the others wait for 50ms each
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("5 requests at 20 rps took %v", elapsed)
+	}
+
+	// Crawl-delay lowers the rate
+	if rate := s.rate(time.Second); rate != 1 {
+		t.Errorf("rate with 1s crawl delay = %v", rate)
+	}
+	if rate := s.rate(10 * time.Millisecond); rate != 20 {
+		t.Errorf("rate with 10ms crawl delay = %v", rate)
+	}
+}
+
+func TestSchedulerMaxInFlight(t *testing.T) {
+	s := newScheduler(PolitenessConfig{MaxInFlightPerHost: 2})
+
+	var inFlight, maxInFlight int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := s.acquire(context.Background(), "example.com", 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight != 2 {
+		t.Errorf("max requests in flight = %d, expected 2", maxInFlight)
+	}
+
+	// Cancelled waiting for a slot
+	release, _ := s.acquire(context.Background(), "example.com", 0)
+	defer release()
+	release2, _ := s.acquire(context.Background(), "example.com", 0)
+	defer release2()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.acquire(ctx, "example.com", 0); err == nil {
+		t.Errorf("slot is taken over the limit")
+	}
+}
+
+func TestRetryAfterPause(t *testing.T) {
+	var requests int32
+	var retriedAt time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == ROBOTS_PATH {
+			http.NotFound(w, r)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		retriedAt = time.Now()
+		w.Write([]byte("<html><title>ok</title></html>"))
+	}))
+	defer srv.Close()
+
+	c := &Crawler{Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+	start := time.Now()
+	page, err := c.ParsePage(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Attempts != 2 || page.Title != "ok" {
+		t.Errorf("attempts %d, title %q", page.Attempts, page.Title)
+	}
+	// The retry delay is 1ms, the host is paused by Retry-After
+	if pause := retriedAt.Sub(start); pause < 900*time.Millisecond {
+		t.Errorf("retried after %v, expected Retry-After pause of 1s", pause)
+	}
+}
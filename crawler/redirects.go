@@ -0,0 +1,157 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const DEFAULT_MAX_REDIRECTS = 10
+
+// Kinds of redirect issues
+const (
+	REDIRECT_LONG_CHAIN   = "longChain"   // more than one hop
+	REDIRECT_CROSS_DOMAIN = "crossDomain" // redirected to another domain
+	REDIRECT_LOOP         = "loop"
+	REDIRECT_TOO_MANY     = "tooMany" // more than MaxRedirects hops
+)
+
+var ErrRedirectLoop = errors.New("Redirect loop")
+var ErrTooManyRedirects = errors.New("Too many redirects")
+
+// Single redirect response
+type RedirectHop struct {
+	Url        string `json:"url"` // redirected url
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location"` // resolved Location header
+}
+
+// Hops of the request redirects, the recorder is passed with the request context
+type redirectRecorder struct {
+	mu   sync.Mutex
+	hops []RedirectHop
+}
+
+type redirectRecorderKey struct{}
+
+func withRedirectRecorder(ctx context.Context) (context.Context, *redirectRecorder) {
+	recorder := &redirectRecorder{}
+	return context.WithValue(ctx, redirectRecorderKey{}, recorder), recorder
+}
+
+func redirectRecorderFrom(ctx context.Context) *redirectRecorder {
+	recorder, _ := ctx.Value(redirectRecorderKey{}).(*redirectRecorder)
+	return recorder
+}
+
+// Keeps the context cancellation, but not the recorder of the page, e.g. for robots.txt fetched by the page
+func withoutRedirectRecorder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, redirectRecorderKey{}, (*redirectRecorder)(nil))
+}
+
+func (r *redirectRecorder) add(hop RedirectHop) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hops = append(r.hops, hop)
+}
+
+// Forgets the hops of the previous attempt
+func (r *redirectRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hops = nil
+}
+
+func (r *redirectRecorder) chain() []RedirectHop {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RedirectHop(nil), r.hops...)
+}
+
+// http.Client CheckRedirect: records the hop and stops on loops and after maxRedirects hops
+func checkRedirect(maxRedirects int) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if recorder := redirectRecorderFrom(req.Context()); recorder != nil {
+			hop := RedirectHop{Url: via[len(via)-1].URL.String(), Location: req.URL.String()}
+			if req.Response != nil {
+				hop.StatusCode = req.Response.StatusCode
+			}
+			recorder.add(hop)
+		}
+
+		for _, prev := range via {
+			if prev.URL.String() == req.URL.String() {
+				return ErrRedirectLoop
+			}
+		}
+		if len(via) > maxRedirects {
+			return ErrTooManyRedirects
+		}
+		return nil
+	}
+}
+
+// Redirected page
+type RedirectIssue struct {
+	Kind     string        `json:"kind"` // one of REDIRECT_*
+	Url      string        `json:"url"`
+	FinalUrl string        `json:"finalUrl,omitempty"` // empty for loops and too many redirects
+	Hops     int           `json:"hops"`
+	Chain    []RedirectHop `json:"chain"`
+}
+
+type RedirectReport struct {
+	RedirectsNum int             `json:"redirectsNum"` // redirected pages
+	LongChains   []RedirectIssue `json:"longChains"`
+	CrossDomain  []RedirectIssue `json:"crossDomain"`
+	Loops        []RedirectIssue `json:"loops"` // loops and too many redirects
+}
+
+// Reports redirect chains longer than one hop, redirects to other domains and redirect loops of the crawled pages
+func BuildRedirectReport(levels []CrawledLevel) RedirectReport {
+	report := RedirectReport{
+		LongChains:  make([]RedirectIssue, 0),
+		CrossDomain: make([]RedirectIssue, 0),
+		Loops:       make([]RedirectIssue, 0),
+	}
+
+	for _, lvl := range levels {
+		for _, page := range lvl.CrawledPages {
+			if len(page.RedirectChain) == 0 {
+				continue
+			}
+			report.RedirectsNum++
+			issue := RedirectIssue{Url: page.Url, FinalUrl: page.FinalUrl, Hops: len(page.RedirectChain),
+				Chain: page.RedirectChain}
+
+			switch page.ErrorKind {
+			case ERR_KIND_REDIRECT_LOOP, ERR_KIND_TOO_MANY_REDIRECTS:
+				issue.Kind = REDIRECT_LOOP
+				if page.ErrorKind == ERR_KIND_TOO_MANY_REDIRECTS {
+					issue.Kind = REDIRECT_TOO_MANY
+				}
+				issue.FinalUrl = ""
+				report.Loops = append(report.Loops, issue)
+				continue
+			}
+
+			if issue.Hops > 1 {
+				issue.Kind = REDIRECT_LONG_CHAIN
+				report.LongChains = append(report.LongChains, issue)
+			}
+			if pageUrl, err := url.Parse(page.Url); err == nil {
+				for _, hop := range page.RedirectChain {
+					if !isInternalLink(pageUrl, hop.Location) {
+						issue.Kind = REDIRECT_CROSS_DOMAIN
+						report.CrossDomain = append(report.CrossDomain, issue)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return report
+}
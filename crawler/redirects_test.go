@@ -0,0 +1,111 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRedirectChain(t *testing.T) {
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><title>external</title></html>"))
+	}))
+	defer external.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/one":
+			http.Redirect(w, r, "/two", http.StatusMovedPermanently)
+		case r.URL.Path == "/two":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case r.URL.Path == "/loop-a":
+			http.Redirect(w, r, "/loop-b", http.StatusFound)
+		case r.URL.Path == "/loop-b":
+			http.Redirect(w, r, "/loop-a", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/many/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/many/"))
+			http.Redirect(w, r, "/many/"+strconv.Itoa(n+1), http.StatusFound)
+		case r.URL.Path == "/away":
+			// Other host of the same machine
+			http.Redirect(w, r, strings.Replace(external.URL, "127.0.0.1", "localhost", 1)+"/",
+				http.StatusMovedPermanently)
+		default:
+			w.Write([]byte("<html><title>final</title></html>"))
+		}
+	}))
+	defer srv.Close()
+
+	c := &Crawler{}
+	ctx := context.Background()
+
+	page, err := c.ParsePage(ctx, srv.URL+"/one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []RedirectHop{
+		{srv.URL + "/one", http.StatusMovedPermanently, srv.URL + "/two"},
+		{srv.URL + "/two", http.StatusFound, srv.URL + "/final"},
+	}
+	if len(page.RedirectChain) != len(expected) {
+		t.Fatalf("chain = %+v, expected %+v", page.RedirectChain, expected)
+	}
+	for i := range expected {
+		if page.RedirectChain[i] != expected[i] {
+			t.Errorf("hop %d = %+v, expected %+v", i, page.RedirectChain[i], expected[i])
+		}
+	}
+
+	loop, err := c.ParsePage(ctx, srv.URL+"/loop-a")
+	if err == nil || loop.ErrorKind != ERR_KIND_REDIRECT_LOOP || len(loop.RedirectChain) != 2 {
+		t.Errorf("loop = %+v, err %v", loop, err)
+	}
+
+	many, err := c.ParsePage(ctx, srv.URL+"/many/0")
+	if err == nil || many.ErrorKind != ERR_KIND_TOO_MANY_REDIRECTS ||
+		len(many.RedirectChain) != DEFAULT_MAX_REDIRECTS+1 {
+		t.Errorf("too many = %s, %d hops, err %v", many.ErrorKind, len(many.RedirectChain), err)
+	}
+
+	away, err := c.ParsePage(ctx, srv.URL+"/away")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	direct, err := c.ParsePage(ctx, srv.URL+"/final")
+	if err != nil || len(direct.RedirectChain) != 0 {
+		t.Errorf("not redirected page chain = %+v, err %v", direct.RedirectChain, err)
+	}
+
+	report := BuildRedirectReport([]CrawledLevel{{CrawledPages: []CrawledPage{page, loop, many, away, direct}}})
+	if report.RedirectsNum != 4 {
+		t.Errorf("redirects num = %d, expected 4", report.RedirectsNum)
+	}
+	if len(report.LongChains) != 1 || report.LongChains[0].Url != page.Url || report.LongChains[0].Hops != 2 {
+		t.Errorf("long chains = %+v", report.LongChains)
+	}
+	if len(report.CrossDomain) != 1 || report.CrossDomain[0].Url != away.Url {
+		t.Errorf("cross domain = %+v", report.CrossDomain)
+	}
+	if len(report.Loops) != 2 || report.Loops[0].Kind != REDIRECT_LOOP || report.Loops[1].Kind != REDIRECT_TOO_MANY {
+		t.Errorf("loops = %+v", report.Loops)
+	}
+}
+
+func TestRobotsRedirectNotInChain(t *testing.T) {
+	srv := newFixtureServer(t, map[string][]fixture{
+		ROBOTS_PATH:    {{status: http.StatusMovedPermanently, header: map[string]string{"Location": "/robots2.txt"}}},
+		"/robots2.txt": {{header: map[string]string{"Content-Type": "text/plain"}, body: "User-agent: *\nDisallow:\n"}},
+		"/page":        {{body: "<html><title>page</title></html>"}},
+	})
+
+	page, err := (&Crawler{}).ParsePage(context.Background(), srv.URL+"/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.RedirectChain) != 0 {
+		t.Errorf("chain = %+v, expected no hops", page.RedirectChain)
+	}
+}
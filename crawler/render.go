@@ -0,0 +1,23 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+)
+
+// Renderers selectable per crawling
+const (
+	RENDERER_STATIC = "static" // Fetcher, the page html as it's served
+	RENDERER_CHROME = "chrome" // ChromeRenderer, the page DOM after JavaScript is run
+)
+
+// Gets the page for the parsing. The body of the response is the html to extract,
+// the status, headers and the url of the request are the ones of the page document
+type Renderer interface {
+	Render(ctx context.Context, link string) (*http.Response, error)
+}
+
+// Static rendering: the page is got by GET request
+func (f *Fetcher) Render(ctx context.Context, link string) (*http.Response, error) {
+	return f.Get(ctx, link)
+}
@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Resolves relativeLink found on the page located at linkAbsoluteLocation(RFC 3986)
+func ExtendRelativeLink(relativeLink string, linkAbsoluteLocation string) (absoluteUrl string, err error) {
+	base, err := url.Parse(strings.TrimSpace(linkAbsoluteLocation))
+	if err != nil {
+		return "", errors.New("Can't parse absolute location: \"" + linkAbsoluteLocation + "\"")
+	}
+
+	return ResolveLink(base, relativeLink)
+}
+
+// Resolves the link against the base url(RFC 3986).
+// Only http(s) links are resolved, mailto:, tel:, javascript: etc. are errors
+func ResolveLink(base *url.URL, link string) (absoluteUrl string, err error) {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return "", errors.New("Empty relativeLink")
+	}
+
+	ref, err := url.Parse(link)
+	if err != nil {
+		return "", errors.New("Can't parse relative link: \"" + link + "\"")
+	}
+
+	absolute := base.ResolveReference(ref)
+	if absolute.Scheme != "http" && absolute.Scheme != "https" {
+		return "", errors.New("Not http(s) link: \"" + link + "\"")
+	}
+	if absolute.Host == "" {
+		return "", errors.New("Can't resolve relative link: \"" + link + "\" without host")
+	}
+
+	return absolute.String(), nil
+}
+
+// Returns the url relative links of the page are resolved against:
+// href of the first <base> resolved against the page url or the page url itself if there is no <base href>
+func pageBaseUrl(baseHref string, pageUrl *url.URL) *url.URL {
+	if baseHref == "" {
+		return pageUrl
+	}
+
+	baseRef, err := url.Parse(strings.TrimSpace(baseHref))
+	if err != nil {
+		return pageUrl
+	}
+
+	return pageUrl.ResolveReference(baseRef)
+}
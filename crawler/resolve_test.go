@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtendRelativeLink(t *testing.T) {
+	absLocat := "https://domain.com/one/two/three"
+
+	tests := []struct {
+		name     string
+		relative string
+		location string
+		expected string
+	}{
+		{"path/path1", "path/path1", absLocat, "https://domain.com/one/two/path/path1"},
+		{"../path", "../path", absLocat, "https://domain.com/one/path"},
+		{"/path/path1", "/path/path1", absLocat, "https://domain.com/path/path1"},
+		{"//path/path1", "//path/path1", absLocat, "https://path/path1"},
+		{"already absolute", "http://domain.com/path", absLocat, "http://domain.com/path"},
+		{"./x", "./x", absLocat, "https://domain.com/one/two/x"},
+		{"./ in directory", "./x", "https://domain.com/one/two/", "https://domain.com/one/two/x"},
+		{"../../x", "../../x", absLocat, "https://domain.com/x"},
+		{"../ above root", "../../../../x", absLocat, "https://domain.com/x"},
+		{"dot segments inside", "a/./b/../c", absLocat, "https://domain.com/one/two/a/c"},
+		{"query only", "?id=1", absLocat, "https://domain.com/one/two/three?id=1"},
+		{"fragment only", "#header", absLocat, "https://domain.com/one/two/three#header"},
+		{"query replaces query", "?b=2", "https://domain.com/page?a=1", "https://domain.com/page?b=2"},
+		{"file location", "news/start.htm", "https://www.study.ua/program-7819.htm",
+			"https://www.study.ua/news/start.htm"},
+		{"percent encoded", "/a%20b/", absLocat, "https://domain.com/a%20b/"},
+		{"space", "/consultation/?theme=a b", absLocat, "https://domain.com/consultation/?theme=a b"},
+		{"tilde", "~user/page", absLocat, "https://domain.com/one/two/~user/page"},
+		{"unicode", "/q/Universität", absLocat, "https://domain.com/q/Universit%C3%A4t"},
+		{"no trailing slash added", "/about", absLocat, "https://domain.com/about"},
+		{"surrounding spaces", "  /about/  ", absLocat, "https://domain.com/about/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ExtendRelativeLink(tt.relative, tt.location)
+			if err != nil {
+				t.Fatalf("ExtendRelativeLink(%q, %q) error: %v", tt.relative, tt.location, err)
+			}
+			if res != tt.expected {
+				t.Errorf("ExtendRelativeLink(%q, %q) = %q, expected %q", tt.relative, tt.location, res, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtendRelativeLinkErrors(t *testing.T) {
+	absLocat := "https://domain.com/one/two/three"
+
+	tests := []struct {
+		name     string
+		relative string
+	}{
+		{"empty", ""},
+		{"spaces only", "   "},
+		{"mailto", "mailto:info@domain.com"},
+		{"tel", "tel: 0970000320"},
+		{"javascript", "javascript:void(0)"},
+		{"broken escape", "/a%zz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res, err := ExtendRelativeLink(tt.relative, absLocat); err == nil {
+				t.Errorf("ExtendRelativeLink(%q) = %q, expected error", tt.relative, res)
+			}
+		})
+	}
+}
+
+func TestPageBaseUrl(t *testing.T) {
+	pageUrl, _ := url.Parse("https://domain.com/one/two/three")
+
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{"no base", `<html><head></head><body><a href="x"></a></body></html>`, "https://domain.com/one/two/x"},
+		{"absolute base", `<html><head><base href="https://cdn.domain.com/root/"></head><body><a href="x"></a></body></html>`,
+			"https://cdn.domain.com/root/x"},
+		{"relative base", `<html><head><base href="/base/"></head><body><a href="x"></a></body></html>`,
+			"https://domain.com/base/x"},
+		{"base without href", `<html><head><base target="_blank"></head><body><a href="x"></a></body></html>`,
+			"https://domain.com/one/two/x"},
+		{"base after link", `<html><body><a href="x"></a><base href="/base/"></body></html>`,
+			"https://domain.com/base/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := CrawledPage{HreflangUrlMap: make(map[string]string)}
+			if err := extractPage(strings.NewReader(tt.html), &Document{Url: pageUrl}, DefaultExtractors(), &page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Links) != 1 || page.Links[0] != tt.expected {
+				t.Errorf("resolved links %q, expected %q", page.Links, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_ATTEMPTS    = 3
+	DEFAULT_RETRY_BASE      = 1 * time.Second
+	DEFAULT_RETRY_MAX_DELAY = 30 * time.Second
+	DEFAULT_RETRY_JITTER    = 0.5
+)
+
+// Retrying of transient fetch failures: timeouts, connection resets, 5xx and 429 statuses.
+// Only GET requests are retried, so retrying is always idempotency-safe
+type RetryPolicy struct {
+	MaxAttempts int           // attempts per url including the first one, 1 if not positive
+	BaseDelay   time.Duration // delay before the second attempt, doubled for each next one
+	MaxDelay    time.Duration // the longest delay between attempts
+	Jitter      float64       // random part of the delay, 0.5 - delay varies from 50% to 150%
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: DEFAULT_MAX_ATTEMPTS,
+		BaseDelay:   DEFAULT_RETRY_BASE,
+		MaxDelay:    DEFAULT_RETRY_MAX_DELAY,
+		Jitter:      DEFAULT_RETRY_JITTER,
+	}
+}
+
+func (rp RetryPolicy) maxAttempts() int {
+	if rp.MaxAttempts <= 0 {
+		return 1
+	}
+	return rp.MaxAttempts
+}
+
+// Delay after the attempt(1-based) with exponential growth and jitter
+func (rp RetryPolicy) delay(attempt int) time.Duration {
+	delay := rp.BaseDelay << uint(attempt-1)
+	if rp.MaxDelay > 0 && (delay > rp.MaxDelay || delay <= 0) {
+		delay = rp.MaxDelay
+	}
+	if rp.Jitter > 0 {
+		delay = time.Duration(float64(delay) * (1 - rp.Jitter + 2*rp.Jitter*rand.Float64()))
+	}
+	return delay
+}
+
+// Checks if the request failure is transient
+func isRetryableError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// Checks if the response status is transient
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Performs GET request by the renderer with retries according to the crawler RetryPolicy.
+// Returns the last response or error and the number of made attempts
+func (c *Crawler) fetch(ctx context.Context, link string, renderer Renderer) (resp *http.Response, attempts int,
+	err error) {
+	return c.fetchAfter(ctx, link, renderer, 0)
+}
+
+// Works like fetch, but the madeAttempts of the link are counted against the RetryPolicy
+func (c *Crawler) fetchAfter(ctx context.Context, link string, renderer Renderer, madeAttempts int) (
+	resp *http.Response, attempts int, err error) {
+	maxAttempts := c.Retry.maxAttempts()
+
+	recorder := redirectRecorderFrom(ctx)
+	for attempts = madeAttempts + 1; ; attempts++ {
+		if recorder != nil {
+			recorder.reset()
+		}
+		resp, err = c.get(ctx, link, renderer)
+
+		// Check if one more attempt is needed
+		var reason string
+		if err != nil {
+			if ctx.Err() != nil || !isRetryableError(err) {
+				return nil, attempts, err
+			}
+			reason = err.Error()
+		} else if isRetryableStatus(resp.StatusCode) {
+			reason = "status " + strconv.Itoa(resp.StatusCode)
+		} else {
+			return resp, attempts, nil
+		}
+		if attempts >= maxAttempts {
+			return resp, attempts, err
+		}
+
+		// Discard the failed response
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+
+		if !c.waitRetry(ctx, link, attempts, reason) {
+			return nil, attempts, ctx.Err()
+		}
+	}
+}
+
+// Waits for the delay of the RetryPolicy after the failed attempt, false if ctx is done meanwhile
+func (c *Crawler) waitRetry(ctx context.Context, link string, attempts int, reason string) bool {
+	delay := c.Retry.delay(attempts)
+	log.Print("[crawler]\tRetrying in ", delay, " after attempt ", attempts, " (", reason, ") url: ", link)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
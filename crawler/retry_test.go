@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Timeout of the network operation
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Page answered with the statuses in turn, see fixtureServer
+func statusesFixtures(statuses ...int) map[string][]fixture {
+	answers := make([]fixture, 0, len(statuses))
+	for _, status := range statuses {
+		answers = append(answers, fixture{status: status, body: "<html><title>page</title></html>"})
+	}
+	return map[string][]fixture{"/": answers}
+}
+
+func TestRetryTransientStatus(t *testing.T) {
+	srv := newFixtureServer(t, statusesFixtures(http.StatusServiceUnavailable, http.StatusOK))
+
+	c := &Crawler{
+		Politeness: PolitenessConfig{Backoff: time.Millisecond},
+		Retry:      RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	}
+	page, err := c.ParsePage(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.StatusCode != http.StatusOK || page.Attempts != 2 || srv.Requests("/") != 2 {
+		t.Errorf("status %d, attempts %d, requests %d", page.StatusCode, page.Attempts, srv.Requests("/"))
+	}
+}
+
+func TestRetryAttemptsExhausted(t *testing.T) {
+	srv := newFixtureServer(t, statusesFixtures(http.StatusInternalServerError))
+
+	c := &Crawler{Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	page, err := c.ParsePage(context.Background(), srv.URL+"/")
+	if err == nil {
+		t.Errorf("failed page has no error")
+	}
+	if page.StatusCode != http.StatusInternalServerError || page.ErrorKind != ERR_KIND_STATUS ||
+		page.Attempts != 3 || srv.Requests("/") != 3 {
+		t.Errorf("status %d, error kind %q, attempts %d, requests %d", page.StatusCode, page.ErrorKind,
+			page.Attempts, srv.Requests("/"))
+	}
+}
+
+func TestNoRetryOfPermanentStatus(t *testing.T) {
+	srv := newFixtureServer(t, statusesFixtures(http.StatusNotFound))
+
+	c := &Crawler{Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	page, _ := c.ParsePage(context.Background(), srv.URL+"/")
+	if page.StatusCode != http.StatusNotFound || page.Attempts != 1 || srv.Requests("/") != 1 {
+		t.Errorf("status %d, attempts %d, requests %d", page.StatusCode, page.Attempts, srv.Requests("/"))
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	srv := newFixtureServer(t, statusesFixtures(http.StatusServiceUnavailable, http.StatusOK))
+
+	c := &Crawler{Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	page, err := c.ParsePage(ctx, srv.URL+"/")
+	if err == nil || page.ErrorKind != ERR_KIND_CANCELLED ||
+		srv.Requests("/") != 1 {
+		t.Errorf("err %v, error kind %q, requests %d", err, page.ErrorKind, srv.Requests("/"))
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	rp := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range expected {
+		if d := rp.delay(i + 1); d != delay {
+			t.Errorf("delay after attempt %d = %v, expected %v", i+1, d, delay)
+		}
+	}
+
+	rp.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := rp.delay(1); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("delay with jitter = %v", d)
+		}
+	}
+
+	if (RetryPolicy{}).maxAttempts() != 1 {
+		t.Errorf("zero policy makes more than one attempt")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	retryable := []error{syscall.ECONNRESET, io.ErrUnexpectedEOF, timeoutError{}}
+	for _, err := range retryable {
+		if !isRetryableError(err) {
+			t.Errorf("%v isn't retryable", err)
+		}
+	}
+	if isRetryableError(errors.New("bad request")) || isRetryableError(ErrRedirectLoop) {
+		t.Errorf("permanent error is retryable")
+	}
+
+	for status, expected := range map[int]bool{200: false, 404: false, 429: true, 500: true, 503: true} {
+		if isRetryableStatus(status) != expected {
+			t.Errorf("isRetryableStatus(%d) = %v", status, !expected)
+		}
+	}
+}
+
+func TestRetryBodyReadFailure(t *testing.T) {
+	const page = "<html><title>page</title></html>"
+	srv := newFixtureServer(t, map[string][]fixture{"/": {{body: page, cut: true}, {body: page}},
+		"/cut": {{body: page, cut: true}}})
+
+	c := &Crawler{Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	crawled, err := c.ParsePage(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crawled.Title != "page" || crawled.Attempts != 2 || srv.Requests("/") != 2 {
+		t.Errorf("title %q, attempts %d, requests %d", crawled.Title, crawled.Attempts, srv.Requests("/"))
+	}
+
+	// Body failures and request ones share the attempts
+	c.Retry.MaxAttempts = 2
+	crawled, err = c.ParsePage(context.Background(), srv.URL+"/cut")
+	if err == nil || crawled.ErrorKind != ERR_KIND_CONNECTION || crawled.Attempts != 2 || srv.Requests("/cut") != 2 {
+		t.Errorf("kind %q, attempts %d, requests %d, err %v", crawled.ErrorKind, crawled.Attempts,
+			srv.Requests("/cut"), err)
+	}
+}
@@ -0,0 +1,190 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"go-crawler/robots"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	ROBOTS_PATH           = "/robots.txt"
+	ROBOTS_RETRY_INTERVAL = time.Minute // unreachable robots.txt is fetched again after it
+)
+
+// Unreachable robots.txt, the links of the hosts were disallowed
+const STOP_REASON_ROBOTS_UNREACHABLE = "robotsUnreachable"
+
+// robots.txt of a host, fetched by the first link of the host while the others wait for it
+type robotsEntry struct {
+	ready   chan struct{} // closed when robots.txt is fetched
+	robots  robots.Robots
+	err     error
+	expires time.Time // of the failure
+}
+
+// Fetches and parses robots.txt of the site with retries according to the crawler RetryPolicy.
+// Not found robots.txt(4xx) allows everything,
+// unreachable robots.txt(5xx, 429, network errors) disallows everything and is returned with the error
+func (c *Crawler) GetRobots(ctx context.Context, siteUrl string) (robots.Robots, error) {
+	u, err := url.Parse(siteUrl)
+	if err != nil {
+		return robots.AllowAll(), err
+	}
+	robotsUrl := u.Scheme + "://" + u.Host + ROBOTS_PATH
+
+	// Redirects of robots.txt are not the redirects of the page it's fetched for
+	resp, _, err := c.fetch(withoutRedirectRecorder(ctx), robotsUrl, c.fetcher())
+	if err != nil {
+		return robots.DisallowAll(), err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return robots.DisallowAll(), errors.New("Unreachable robots.txt with status: " +
+			strconv.Itoa(resp.StatusCode))
+	case resp.StatusCode >= 400:
+		return robots.AllowAll(), nil
+	case resp.StatusCode != 200:
+		return robots.AllowAll(), nil
+	}
+
+	return robots.Parse(resp.Body), nil
+}
+
+// Returns robots.txt rules of the link host. robots.txt is fetched once per host,
+// failures are fetched again after ROBOTS_RETRY_INTERVAL
+func (c *Crawler) robotsFor(ctx context.Context, link string) robots.Robots {
+	u, err := url.Parse(link)
+	if err != nil {
+		return robots.AllowAll()
+	}
+	host := u.Scheme + "://" + u.Host
+
+	c.robotsMu.Lock()
+	entry, ok := c.robotsCache[host]
+	if ok {
+		select {
+		case <-entry.ready:
+			ok = entry.err == nil || time.Now().Before(entry.expires)
+		default:
+		}
+	}
+	if !ok {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		if c.robotsCache == nil {
+			c.robotsCache = make(map[string]*robotsEntry)
+		}
+		c.robotsCache[host] = entry
+	}
+	c.robotsMu.Unlock()
+
+	if !ok {
+		c.readRobots(ctx, host, entry)
+		return entry.robots
+	}
+
+	select {
+	case <-entry.ready:
+		return entry.robots
+	case <-ctx.Done():
+		return robots.DisallowAll()
+	}
+}
+
+// Fetches robots.txt of the host into the entry
+func (c *Crawler) readRobots(ctx context.Context, host string, entry *robotsEntry) {
+	defer close(entry.ready)
+
+	entry.robots, entry.err = c.GetRobots(ctx, host)
+	switch {
+	case entry.err != nil && ctx.Err() != nil: // do not cache cancelled fetching
+		c.robotsMu.Lock()
+		if c.robotsCache[host] == entry {
+			delete(c.robotsCache, host)
+		}
+		c.robotsMu.Unlock()
+	case entry.err != nil:
+		entry.expires = time.Now().Add(ROBOTS_RETRY_INTERVAL)
+		log.Print("[crawler]\tFailed to read robots.txt of ", host, " with error: \"", entry.err.Error(), "\"")
+	default:
+		log.Print("[crawler]\tRead robots.txt of ", host, " with ", len(entry.robots.Groups), " groups")
+	}
+}
+
+// Returns the errors of robots.txt by scheme://host which were unreachable at the last fetching.
+// Empty if the crawler ignores robots.txt, as no links were disallowed then
+func (c *Crawler) RobotsFailures() map[string]string {
+	failures := make(map[string]string)
+	if c.IgnoreRobots {
+		return failures
+	}
+
+	c.robotsMu.Lock()
+	defer c.robotsMu.Unlock()
+
+	for host, entry := range c.robotsCache {
+		select {
+		case <-entry.ready:
+			if entry.err != nil {
+				failures[host] = entry.err.Error()
+			}
+		default:
+		}
+	}
+
+	return failures
+}
+
+// Logs unreachable robots.txt and returns STOP_REASON_ROBOTS_UNREACHABLE appended to the reasons if there are any
+func (c *Crawler) AppendRobotsStopReason(reasons []string) []string {
+	failures := c.RobotsFailures()
+	if len(failures) == 0 {
+		return reasons
+	}
+
+	hosts := make([]string, 0, len(failures))
+	for host := range failures {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		log.Print("[crawler]\tLinks were disallowed because of unreachable robots.txt of ", host,
+			", error: \"", failures[host], "\"")
+	}
+
+	return append(reasons, STOP_REASON_ROBOTS_UNREACHABLE)
+}
+
+// Checks link against robots.txt of it's host, always true if the crawler ignores robots.txt
+func (c *Crawler) IsAllowedByRobots(ctx context.Context, link string) bool {
+	if c.IgnoreRobots {
+		return true
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	return c.robotsFor(ctx, link).IsAllowed(c.fetcher().Config().UserAgent, u.RequestURI())
+}
+
+// Filters out links disallowed by robots.txt
+func (c *Crawler) FilterDisallowedByRobots(ctx context.Context, links []string) (allowed []string) {
+	for _, link := range links {
+		if c.IsAllowedByRobots(ctx, link) {
+			allowed = append(allowed, link)
+		} else {
+			log.Print("[crawler]\tDisallowed by robots.txt: ", link)
+		}
+	}
+
+	return allowed
+}
@@ -0,0 +1,101 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// robots.txt answered with the statuses in turn, see fixtureServer
+func robotsFixtures(statuses ...int) map[string][]fixture {
+	answers := make([]fixture, 0, len(statuses))
+	for _, status := range statuses {
+		answers = append(answers, fixture{status: status, header: map[string]string{"Content-Type": "text/plain"},
+			body: "User-agent: *\nDisallow: /private\n"})
+	}
+	return map[string][]fixture{ROBOTS_PATH: answers}
+}
+
+func TestRobotsFetchedOnceWithRetries(t *testing.T) {
+	srv := newFixtureServer(t, robotsFixtures(http.StatusInternalServerError, http.StatusOK))
+
+	c := &Crawler{Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !c.IsAllowedByRobots(context.Background(), srv.URL+"/page") {
+				t.Errorf("page is disallowed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.IsAllowedByRobots(context.Background(), srv.URL+"/private") {
+		t.Errorf("private page is allowed")
+	}
+	if n := srv.Requests(ROBOTS_PATH); n != 2 {
+		t.Errorf("robots.txt requests = %d, expected 2", n)
+	}
+	if failures := c.RobotsFailures(); len(failures) != 0 {
+		t.Errorf("failures = %v", failures)
+	}
+}
+
+func TestRobotsUnreachable(t *testing.T) {
+	srv := newFixtureServer(t, robotsFixtures(http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK))
+
+	c := &Crawler{Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+	if c.IsAllowedByRobots(context.Background(), srv.URL+"/page") {
+		t.Errorf("page is allowed by unreachable robots.txt")
+	}
+	if c.IsAllowedByRobots(context.Background(), srv.URL+"/other") || srv.Requests(ROBOTS_PATH) != 2 {
+		t.Errorf("failure isn't cached, requests = %d", srv.Requests(ROBOTS_PATH))
+	}
+	if failures := c.RobotsFailures(); len(failures) != 1 || failures[srv.URL] == "" {
+		t.Errorf("failures = %v", failures)
+	}
+	if reasons := c.AppendRobotsStopReason(nil); len(reasons) != 1 || reasons[0] != STOP_REASON_ROBOTS_UNREACHABLE {
+		t.Errorf("stop reasons = %v", reasons)
+	}
+
+	// Expired failure is fetched again
+	c.robotsMu.Lock()
+	c.robotsCache[srv.URL].expires = time.Now()
+	c.robotsMu.Unlock()
+	if !c.IsAllowedByRobots(context.Background(), srv.URL+"/page") || srv.Requests(ROBOTS_PATH) != 3 {
+		t.Errorf("expired failure isn't fetched again, requests = %d", srv.Requests(ROBOTS_PATH))
+	}
+	if failures := c.RobotsFailures(); len(failures) != 0 {
+		t.Errorf("failures = %v", failures)
+	}
+}
+
+func TestRobotsNotFound(t *testing.T) {
+	srv := newFixtureServer(t, robotsFixtures(http.StatusNotFound))
+
+	c := &Crawler{}
+	if !c.IsAllowedByRobots(context.Background(), srv.URL+"/private") {
+		t.Errorf("not found robots.txt disallows")
+	}
+}
+
+func TestRobotsUnreachableIgnored(t *testing.T) {
+	srv := newFixtureServer(t, robotsFixtures(http.StatusInternalServerError))
+
+	// Sitemap discovery reads robots.txt even if the crawler ignores it
+	c := &Crawler{IgnoreRobots: true}
+	c.GetSitemapUrls(context.Background(), srv.URL)
+	if !c.IsAllowedByRobots(context.Background(), srv.URL+"/private") {
+		t.Errorf("page is disallowed by ignored robots.txt")
+	}
+	if failures := c.RobotsFailures(); len(failures) != 0 {
+		t.Errorf("failures = %v", failures)
+	}
+	if reasons := c.AppendRobotsStopReason([]string{STOP_REASON_MAX_PAGES}); len(reasons) != 1 {
+		t.Errorf("stop reasons = %v", reasons)
+	}
+}
@@ -0,0 +1,219 @@
+package crawler
+
+import (
+	"golang.org/x/net/html"
+	"mime"
+	"strings"
+)
+
+// Robots directives with values, "name: value" of them isn't a bot name
+var ROBOTS_VALUE_DIRECTIVES = []string{"unavailable_after", "max-snippet", "max-image-preview", "max-video-preview"}
+
+// Bots with their own robots meta, e.g. <meta name=googlebot>
+var ROBOTS_META_BOTS = []string{"googlebot", "googlebot-news", "bingbot", "yandex", "slurp", "duckduckbot", "baiduspider"}
+
+// Indexing directives of <meta name=robots> and X-Robots-Tag
+type RobotsDirectives struct {
+	NoIndex    bool     `json:"noIndex"`
+	NoFollow   bool     `json:"noFollow"`
+	NoArchive  bool     `json:"noArchive"`
+	NoSnippet  bool     `json:"noSnippet"`
+	Directives []string `json:"directives,omitempty"` // all the directives as they are, lowercased
+}
+
+// Adds the comma separated directives
+func (d *RobotsDirectives) parse(directives string) {
+	for _, directive := range strings.Split(directives, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "" {
+			continue
+		}
+		d.Directives = append(d.Directives, directive)
+
+		switch directive {
+		case "noindex":
+			d.NoIndex = true
+		case "nofollow":
+			d.NoFollow = true
+		case "none":
+			d.NoIndex, d.NoFollow = true, true
+		case "noarchive":
+			d.NoArchive = true
+		case "nosnippet":
+			d.NoSnippet = true
+		}
+	}
+}
+
+type Heading struct {
+	Level int    `json:"level"` // 1-6
+	Text  string `json:"text"`
+}
+
+// SEO related data of the page
+type SeoMeta struct {
+	Description string                      `json:"description,omitempty"`
+	Keywords    string                      `json:"keywords,omitempty"`
+	Robots      RobotsDirectives            `json:"robots"`              // meta robots and X-Robots-Tag for all the bots
+	BotRobots   map[string]RobotsDirectives `json:"botRobots,omitempty"` // by lowercased bot name, e.g. googlebot
+	XRobotsTag  []string                    `json:"xRobotsTag,omitempty"`
+	Headings    []Heading                   `json:"headings,omitempty"` // h1-h6 in the document order
+	OpenGraph   map[string]string           `json:"openGraph,omitempty"`
+	Twitter     map[string]string           `json:"twitter,omitempty"`
+	Lang        string                      `json:"lang,omitempty"`    // <html lang>
+	Charset     string                      `json:"charset,omitempty"` // meta charset or Content-Type charset
+}
+
+// Adds the directives for the bot, all the bots if the bot is empty
+func (m *SeoMeta) addRobots(bot string, directives string) {
+	if bot == "" || bot == "robots" {
+		m.Robots.parse(directives)
+		return
+	}
+
+	if m.BotRobots == nil {
+		m.BotRobots = make(map[string]RobotsDirectives)
+	}
+	botDirectives := m.BotRobots[bot]
+	botDirectives.parse(directives)
+	m.BotRobots[bot] = botDirectives
+}
+
+// Adds X-Robots-Tag header value: "noindex, nofollow" or "googlebot: noindex"
+func (m *SeoMeta) addXRobotsTag(value string) {
+	m.XRobotsTag = append(m.XRobotsTag, value)
+
+	bot := ""
+	if colon := strings.Index(value, ":"); colon > 0 {
+		prefix := strings.ToLower(strings.TrimSpace(value[:colon]))
+		if !strings.ContainsAny(prefix, ", ") && !contains(ROBOTS_VALUE_DIRECTIVES, prefix) {
+			bot = prefix
+			value = value[colon+1:]
+		}
+	}
+	m.addRobots(bot, value)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Meta description and keywords, robots meta and X-Robots-Tag, headings, Open Graph, Twitter card, lang and charset.
+// Sets NoIndex if the page is noindex for all the bots
+type SeoExtractor struct{}
+
+func (SeoExtractor) Name() string { return "seo" }
+
+func (SeoExtractor) Start(doc *Document) PageExtraction { return &seoExtraction{doc: doc} }
+
+type seoExtraction struct {
+	doc     *Document
+	meta    SeoMeta
+	heading *Heading // heading being read
+	text    strings.Builder
+}
+
+func (e *seoExtraction) Token(token html.Token) {
+	isStart := token.Type == html.StartTagToken || token.Type == html.SelfClosingTagToken
+
+	// Headings can't be nested, any heading closes the current one
+	if e.heading != nil && (isStart || token.Type == html.EndTagToken) && isHeading(token.Data) {
+		e.heading.Text = strings.TrimSpace(e.text.String())
+		e.meta.Headings = append(e.meta.Headings, *e.heading)
+		e.heading = nil
+		e.text.Reset()
+	}
+
+	switch {
+	case token.Type == html.TextToken && e.heading != nil:
+		e.text.WriteString(token.Data)
+	case !isStart:
+	case isHeading(token.Data) && token.Type == html.StartTagToken:
+		e.heading = &Heading{Level: int(token.Data[1] - '0')}
+	case token.Data == "html":
+		if lang, ok := tokenAttr(token, "lang"); ok && e.meta.Lang == "" {
+			e.meta.Lang = strings.TrimSpace(lang)
+		}
+	case token.Data == "meta":
+		e.metaTag(token)
+	}
+}
+
+func (e *seoExtraction) metaTag(token html.Token) {
+	if charset, ok := tokenAttr(token, "charset"); ok && e.meta.Charset == "" {
+		e.meta.Charset = strings.ToLower(strings.TrimSpace(charset))
+	}
+
+	content, _ := tokenAttr(token, "content")
+	content = strings.TrimSpace(content)
+
+	if httpEquiv, _ := tokenAttr(token, "http-equiv"); strings.EqualFold(strings.TrimSpace(httpEquiv), "content-type") {
+		if _, params, err := mime.ParseMediaType(content); err == nil && e.meta.Charset == "" {
+			e.meta.Charset = strings.ToLower(params["charset"])
+		}
+	}
+
+	name, _ := tokenAttr(token, "name")
+	name = strings.ToLower(strings.TrimSpace(name))
+	property, _ := tokenAttr(token, "property")
+	property = strings.ToLower(strings.TrimSpace(property))
+
+	switch {
+	case name == "description":
+		if e.meta.Description == "" {
+			e.meta.Description = content
+		}
+	case name == "keywords":
+		if e.meta.Keywords == "" {
+			e.meta.Keywords = content
+		}
+	case name == "robots" || contains(ROBOTS_META_BOTS, name):
+		e.meta.addRobots(name, content)
+	case strings.HasPrefix(property, "og:"):
+		e.meta.OpenGraph = addFirst(e.meta.OpenGraph, property, content)
+	case strings.HasPrefix(name, "twitter:"):
+		e.meta.Twitter = addFirst(e.meta.Twitter, name, content)
+	case strings.HasPrefix(property, "twitter:"):
+		e.meta.Twitter = addFirst(e.meta.Twitter, property, content)
+	}
+}
+
+// Sets the value if the key isn't set yet, e.g. the first og:image is kept
+func addFirst(m map[string]string, key string, value string) map[string]string {
+	if m == nil {
+		m = make(map[string]string)
+	}
+	if _, ok := m[key]; !ok {
+		m[key] = value
+	}
+	return m
+}
+
+func (e *seoExtraction) Finish(page *CrawledPage) (any, error) {
+	// Not closed heading
+	if e.heading != nil {
+		e.heading.Text = strings.TrimSpace(e.text.String())
+		e.meta.Headings = append(e.meta.Headings, *e.heading)
+	}
+
+	if resp := e.doc.Response; resp != nil {
+		for _, value := range resp.Header.Values("X-Robots-Tag") {
+			e.meta.addXRobotsTag(value)
+		}
+
+		if e.meta.Charset == "" {
+			if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+				e.meta.Charset = strings.ToLower(params["charset"])
+			}
+		}
+	}
+
+	page.Seo = e.meta
+	page.NoIndex = e.meta.Robots.NoIndex
+	return nil, nil
+}
@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeoExtractor(t *testing.T) {
+	pageUrl, _ := url.Parse("https://domain.com/")
+	html := `<!DOCTYPE html>
+<html lang="uk">
+<head>
+	<meta http-equiv="Content-Type" content="text/html; charset=Windows-1251">
+	<meta name="Description" content=" Page description ">
+	<meta name="keywords" content="a, b">
+	<meta name="robots" content="NOARCHIVE, nosnippet">
+	<meta name="googlebot" content="none">
+	<meta name="googlebot" content="noimageindex">
+	<meta property="og:title" content="OG title">
+	<meta property="og:image" content="/1.png">
+	<meta property="og:image" content="/2.png">
+	<meta name="twitter:card" content="summary">
+</head>
+<body>
+	<h1>Title <span>part</span></h1>
+	<h3>Third</h3>
+	<h2>Second<h4>Fourth</h4>
+	<h6>Not closed`
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Add("Content-Type", "text/html; charset=utf-8")
+	resp.Header.Add("X-Robots-Tag", "unavailable_after: 25 Jun 2030 15:00:00 PST")
+	resp.Header.Add("X-Robots-Tag", "bingbot: noindex, nofollow")
+
+	page := newTestPage()
+	doc := &Document{Url: pageUrl, Response: resp}
+	if err := extractPage(strings.NewReader(html), doc, DefaultExtractors(), &page); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := SeoMeta{
+		Description: "Page description",
+		Keywords:    "a, b",
+		Robots: RobotsDirectives{NoArchive: true, NoSnippet: true,
+			Directives: []string{"noarchive", "nosnippet", "unavailable_after: 25 jun 2030 15:00:00 pst"}},
+		BotRobots: map[string]RobotsDirectives{
+			"googlebot": {NoIndex: true, NoFollow: true, Directives: []string{"none", "noimageindex"}},
+			"bingbot":   {NoIndex: true, NoFollow: true, Directives: []string{"noindex", "nofollow"}},
+		},
+		XRobotsTag: []string{"unavailable_after: 25 Jun 2030 15:00:00 PST", "bingbot: noindex, nofollow"},
+		Headings: []Heading{{1, "Title part"}, {3, "Third"}, {2, "Second"}, {4, "Fourth"},
+			{6, "Not closed"}},
+		OpenGraph: map[string]string{"og:title": "OG title", "og:image": "/1.png"},
+		Twitter:   map[string]string{"twitter:card": "summary"},
+		Lang:      "uk",
+		Charset:   "windows-1251",
+	}
+	if !reflect.DeepEqual(page.Seo, expected) {
+		t.Errorf("Seo = %+v\nexpected %+v", page.Seo, expected)
+	}
+	if page.NoIndex {
+		t.Error("NoIndex = true, expected false as the page is noindex for some bots only")
+	}
+}
+
+func TestSeoNoIndex(t *testing.T) {
+	pageUrl, _ := url.Parse("https://domain.com/")
+
+	tests := []struct {
+		name       string
+		html       string
+		xRobotsTag string
+		expected   bool
+	}{
+		{"meta robots", `<meta name="robots" content="noindex, follow">`, "", true},
+		{"meta robots none", `<meta name="ROBOTS" content="NONE">`, "", true},
+		{"not robots meta", `<meta name="description" content="nofollow,noindex">`, "", false},
+		{"bot meta", `<meta name="googlebot" content="noindex">`, "", false},
+		{"header", ``, "noindex", true},
+		{"bot header", ``, "googlebot: noindex", false},
+		{"index", `<meta name="robots" content="index, follow">`, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.xRobotsTag != "" {
+				resp.Header.Set("X-Robots-Tag", tt.xRobotsTag)
+			}
+			page := newTestPage()
+			doc := &Document{Url: pageUrl, Response: resp}
+			if err := extractPage(strings.NewReader(tt.html), doc, DefaultExtractors(), &page); err != nil {
+				t.Fatal(err)
+			}
+			if page.NoIndex != tt.expected {
+				t.Errorf("NoIndex = %v, expected %v", page.NoIndex, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAX_SITEMAPS        = 1000             // sitemap files to read per site
+	MAX_SITEMAP_DEPTH   = 5                // nesting of sitemap indexes
+	MAX_SITEMAP_SIZE    = 50 * 1024 * 1024 // uncompressed bytes of a single sitemap (sitemaps.org limit)
+	DEFAULT_SITEMAP_URL = "sitemap.xml"
+)
+
+// Places where sitemaps are looked for if neither robots.txt nor /sitemap.xml declare any
+var ALTERNATE_SITEMAP_PATHS = []string{
+	"sitemap_index.xml",
+	"sitemap-index.xml",
+	"sitemap.xml.gz",
+	"wp-sitemap.xml",
+	"sitemap.txt",
+}
+
+// Url entry of a sitemap
+type SitemapUrl struct {
+	Loc        string `xml:"loc" json:"loc"`
+	LastMod    string `xml:"lastmod" json:"lastMod,omitempty"`
+	ChangeFreq string `xml:"changefreq" json:"changeFreq,omitempty"`
+	Priority   string `xml:"priority" json:"priority,omitempty"`
+	Sitemap    string `xml:"-" json:"sitemap"` // sitemap the url is listed in
+}
+
+// <urlset> or <sitemapindex> document
+type xmlSitemap struct {
+	XMLName  xml.Name
+	Urls     []SitemapUrl `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+func GetLinksFromSitemap(siteMainPageUrl string) (sitemapLinks []string, err error) {
+	return (&Crawler{}).GetLinksFromSitemap(context.Background(), siteMainPageUrl)
+}
+
+// Reads links from all the sitemaps of the site using the crawler fetcher, see GetSitemapUrls
+func (c *Crawler) GetLinksFromSitemap(ctx context.Context, siteMainPageUrl string) (sitemapLinks []string, err error) {
+	sitemapUrls, err := c.GetSitemapUrls(ctx, siteMainPageUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	sitemapLinks = make([]string, 0, len(sitemapUrls))
+	for _, su := range sitemapUrls {
+		sitemapLinks = append(sitemapLinks, su.Loc)
+	}
+
+	return sitemapLinks, nil
+}
+
+// Reads url entries from the sitemaps declared in robots.txt and /sitemap.xml of the site,
+// falls back to ALTERNATE_SITEMAP_PATHS if there are none.
+// Sitemap indexes are followed recursively, gzipped and plain text sitemaps are supported.
+// Urls are normalized and unique, the first entry of a url is kept
+func (c *Crawler) GetSitemapUrls(ctx context.Context, siteMainPageUrl string) ([]SitemapUrl, error) {
+	root, err := url.Parse(strings.TrimSpace(siteMainPageUrl))
+	if err != nil || root.Host == "" {
+		return nil, errors.New("Failed to read sitemap of: \"" + siteMainPageUrl + "\" not valid url")
+	}
+	rootUrl := root.Scheme + "://" + root.Host + "/"
+
+	reader := &sitemapReader{crawler: c, seenSitemaps: make(map[string]struct{}), seenUrls: make(map[string]struct{})}
+
+	// Declared sitemaps
+	sitemapUrls := append(c.robotsFor(ctx, rootUrl).Sitemaps, rootUrl+DEFAULT_SITEMAP_URL)
+	for _, sitemapUrl := range sitemapUrls {
+		reader.read(ctx, sitemapUrl, 0)
+	}
+
+	// Guess sitemaps
+	if len(reader.urls) == 0 {
+		for _, path := range ALTERNATE_SITEMAP_PATHS {
+			reader.read(ctx, rootUrl+path, 0)
+			if len(reader.urls) > 0 {
+				break
+			}
+		}
+	}
+
+	if len(reader.urls) == 0 {
+		return nil, errors.New("Failed to read sitemap of: \"" + rootUrl + "\" no urls found")
+	}
+
+	log.Print("[crawler]\tFound ", len(reader.urls), " unique links in ", len(reader.seenSitemaps),
+		" sitemaps of "+rootUrl)
+
+	return reader.urls, nil
+}
+
+// State of reading all the sitemaps of a site
+type sitemapReader struct {
+	crawler      *Crawler
+	seenSitemaps map[string]struct{}
+	seenUrls     map[string]struct{}
+	urls         []SitemapUrl
+}
+
+// Reads the sitemap and the sitemaps it refers to, failures are logged and skipped
+func (r *sitemapReader) read(ctx context.Context, sitemapUrl string, depth int) {
+	sitemapUrl = strings.TrimSpace(sitemapUrl)
+	if _, ok := r.seenSitemaps[sitemapUrl]; ok || sitemapUrl == "" {
+		return
+	}
+	if depth > MAX_SITEMAP_DEPTH || len(r.seenSitemaps) >= MAX_SITEMAPS || ctx.Err() != nil {
+		return
+	}
+	r.seenSitemaps[sitemapUrl] = struct{}{}
+
+	urls, sitemaps, err := r.crawler.readSitemap(ctx, sitemapUrl)
+	if err != nil {
+		log.Print("[crawler]\tFailed to read sitemap: \"", sitemapUrl, "\" with error: \"", err.Error(), "\"")
+		return
+	}
+
+	for _, su := range urls {
+		su.Loc = r.crawler.normalize(su.Loc)
+		if _, ok := r.seenUrls[su.Loc]; ok || su.Loc == "" {
+			continue
+		}
+		r.seenUrls[su.Loc] = struct{}{}
+		su.Sitemap = sitemapUrl
+		r.urls = append(r.urls, su)
+	}
+
+	for _, nested := range sitemaps {
+		r.read(ctx, nested, depth+1)
+	}
+}
+
+// Fetches and parses a single sitemap file. Returns its url entries and nested sitemaps of sitemap index
+func (c *Crawler) readSitemap(ctx context.Context, sitemapUrl string) (urls []SitemapUrl, sitemaps []string, err error) {
+	resp, _, err := c.fetch(ctx, sitemapUrl, c.fetcher())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	// Handle not 200 status of original query or last redirect
+	if resp.StatusCode != 200 {
+		return nil, nil, errors.New("Not 200 status code(" + strconv.Itoa(resp.StatusCode) + ")")
+	}
+
+	body := bufio.NewReader(io.LimitReader(resp.Body, MAX_SITEMAP_SIZE))
+
+	// Gzipped sitemap, the magic bytes are checked as servers send it with any content type
+	if magic, _ := body.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gzipReader.Close()
+		body = bufio.NewReader(io.LimitReader(gzipReader, MAX_SITEMAP_SIZE))
+	}
+
+	if isXml(body) {
+		return parseXmlSitemap(body)
+	}
+	return parseTextSitemap(body)
+}
+
+// Checks if the first not space char is '<'
+func isXml(body *bufio.Reader) bool {
+	for n := 1; n <= 1024; n++ {
+		peeked, err := body.Peek(n)
+		if len(peeked) < n {
+			return false
+		}
+		// Skip UTF-8 BOM and spaces, the BOM is not complete until 3 bytes are peeked
+		if n < 3 && bytes.HasPrefix([]byte("\xef\xbb\xbf"), peeked) {
+			continue
+		}
+		trimmed := bytes.TrimLeft(bytes.TrimPrefix(peeked, []byte("\xef\xbb\xbf")), " \t\r\n")
+		if len(trimmed) > 0 {
+			return trimmed[0] == '<'
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+func parseXmlSitemap(body io.Reader) (urls []SitemapUrl, sitemaps []string, err error) {
+	decoder := xml.NewDecoder(body)
+	// Sitemaps must be UTF-8, other declared encodings are read as is
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var doc xmlSitemap
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, nil, err
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, su := range doc.Urls {
+			su.Loc = strings.TrimSpace(su.Loc)
+			su.LastMod = strings.TrimSpace(su.LastMod)
+			su.ChangeFreq = strings.TrimSpace(su.ChangeFreq)
+			su.Priority = strings.TrimSpace(su.Priority)
+			urls = append(urls, su)
+		}
+	case "sitemapindex":
+		for _, sm := range doc.Sitemaps {
+			sitemaps = append(sitemaps, strings.TrimSpace(sm.Loc))
+		}
+	default:
+		return nil, nil, errors.New("Unknown sitemap root element <" + doc.XMLName.Local + ">")
+	}
+
+	return urls, sitemaps, nil
+}
+
+// Plain text sitemap: one url per line
+func parseTextSitemap(body io.Reader) (urls []SitemapUrl, sitemaps []string, err error) {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+			urls = append(urls, SitemapUrl{Loc: line})
+		}
+	}
+
+	return urls, nil, scanner.Err()
+}
@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+)
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+// Files served by path, ".gz" ones are gzipped, see fixtureServer
+func fileFixtures(files map[string]string) map[string][]fixture {
+	fixtures := make(map[string][]fixture, len(files))
+	for path, content := range files {
+		f := fixture{body: content}
+		if strings.HasSuffix(path, ".gz") {
+			f.gzip = true
+			f.header = map[string]string{"Content-Type": "application/octet-stream"}
+		}
+		fixtures[path] = []fixture{f}
+	}
+	return fixtures
+}
+
+func TestGetSitemapUrls(t *testing.T) {
+	srv := newFixtureServer(t, fileFixtures(map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow:\nSitemap: {srv}/declared.xml\n",
+		"/declared.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>{srv}/nested.xml.gz</loc></sitemap>
+	<sitemap><loc> {srv}/list.txt </loc></sitemap>
+	<sitemap><loc>{srv}/declared.xml</loc></sitemap>
+	<sitemap><loc>{srv}/missing.xml</loc></sitemap>
+</sitemapindex>`,
+		"/nested.xml.gz": `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>{srv}/a</loc><lastmod>2024-01-02</lastmod><priority>0.8</priority></url>
+	<url><loc>{srv}/b?utm_source=mail</loc></url>
+</urlset>`,
+		"/list.txt":    "{srv}/c\n{srv}/a\nnot a url\n\n",
+		"/sitemap.xml": "\xef\xbb\xbf\n  <urlset><url><loc>{srv}/d</loc></url></urlset>",
+		"/sitemap.txt": "{srv}/alternate",
+	}))
+
+	urls, err := (&Crawler{}).GetSitemapUrls(context.Background(), srv.URL+"/page")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []SitemapUrl{
+		{Loc: srv.URL + "/a", LastMod: "2024-01-02", Priority: "0.8", Sitemap: srv.URL + "/nested.xml.gz"},
+		{Loc: srv.URL + "/b", Sitemap: srv.URL + "/nested.xml.gz"},
+		{Loc: srv.URL + "/c", Sitemap: srv.URL + "/list.txt"},
+		{Loc: srv.URL + "/d", Sitemap: srv.URL + "/sitemap.xml"},
+	}
+	if len(urls) != len(expected) {
+		t.Fatalf("urls = %+v", urls)
+	}
+	for i := range expected {
+		if urls[i] != expected[i] {
+			t.Errorf("url %d = %+v, expected %+v", i, urls[i], expected[i])
+		}
+	}
+}
+
+func TestAlternateSitemap(t *testing.T) {
+	srv := newFixtureServer(t, fileFixtures(map[string]string{
+		"/sitemap.xml.gz": "<urlset><url><loc>{srv}/gz</loc></url></urlset>",
+		"/sitemap.txt":    "{srv}/txt",
+	}))
+
+	links, err := (&Crawler{}).GetLinksFromSitemap(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 1 || links[0] != srv.URL+"/gz" {
+		t.Errorf("links = %v", links)
+	}
+}
+
+func TestNoSitemap(t *testing.T) {
+	srv := newFixtureServer(t, fileFixtures(map[string]string{"/sitemap.xml": "<html><body>not found</body></html>"}))
+
+	if links, err := (&Crawler{}).GetLinksFromSitemap(context.Background(), srv.URL+"/"); err == nil {
+		t.Errorf("links = %v, expected error", links)
+	}
+	if _, err := (&Crawler{}).GetLinksFromSitemap(context.Background(), "not a url"); err == nil {
+		t.Errorf("not valid url has no error")
+	}
+}
+
+func TestIsXml(t *testing.T) {
+	tests := map[string]bool{
+		"<urlset>":                      true,
+		"\xef\xbb\xbf<?xml version=1?>": true,
+		"\n\t  <urlset>":                true,
+		"https://example.com/":          false,
+		"   ":                           false,
+		"":                              false,
+	}
+	for content, expected := range tests {
+		if result := isXml(bufio.NewReader(strings.NewReader(content))); result != expected {
+			t.Errorf("isXml(%q) = %v, expected %v", content, result, expected)
+		}
+	}
+}
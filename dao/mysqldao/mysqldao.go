@@ -18,27 +18,102 @@ const (
 	ESTIMATOR_TABLE          = "estimator"
 	ESTIMATOR_SETTINGS_TABLE = "estimator_settings"
 	CRAWLED_LINK_EST_TABLE   = "crawled_link_estimation"
+	CRAWL_COVERAGE_TABLE     = "crawl_coverage"
+	CRAWLING_TASK_RULE_TABLE = "crawling_task_rule"
+	CRAWLED_LINK_DATA_TABLE  = "crawled_link_data"
+	CRAWL_ASSET_TABLE        = "crawl_asset"
+	BROKEN_RESOURCE_TABLE    = "crawl_broken_resource"
+	CRAWL_REDIRECT_TABLE     = "crawl_redirect"
+	CRAWL_HEAVY_PAGE_TABLE   = "crawl_heavy_page"
+	CRAWL_DUPLICATE_TABLE    = "crawl_duplicate"
 	DB_CREDENTIALS_FILENAME  = "db_credentials.json"
 	CONNECTION_TIMEOUT       = 5
 	MAX_CONNECTIONS          = 5
 )
 
+// Rows inserted by a single statement of the batch inserts, keeps the placeholders under the limit of 65535
+const BATCH_INSERT_SIZE = 1000
+
 // Crawling statuses representation
 const (
 	IN_QUEUE    = "in_queue"
 	IN_PROGRESS = "in_progress"
 	DONE        = "done"
+	CANCELLED   = "cancelled"
 )
 
+/*
+Columns appended to the crawling_task table after the initial schema:
+alter table crawling_task add ignore_robots boolean default false not null;
+alter table crawling_task add workers int null;
+alter table crawling_task add requests_per_second double null;
+alter table crawling_task add max_in_flight_per_host int null;
+alter table crawling_task add max_depth int null;
+alter table crawling_task add max_pages int null;
+alter table crawling_task add max_pages_per_host int null;
+alter table crawling_task add max_duration_ms bigint null;
+alter table crawling_task add max_bytes bigint null;
+alter table crawling_task add stop_reason varchar(255) null;
+alter table crawling_task add nofollow_policy varchar(16) null comment 'follow, record or ignore';
+alter table crawling_task add check_links boolean default false not null;
+alter table crawling_task add allowed_mime_types varchar(1000) null comment 'comma separated, html if null';
+alter table crawling_task add max_body_size bigint null;
+alter table crawling_task add transfer_bytes bigint null;
+alter table crawling_task add decoded_bytes bigint null;
+alter table crawling_task add renderer varchar(16) null comment 'static or chrome, static if null';
+alter table crawling_task add render_wait varchar(255) null comment 'load, networkIdle or css selector';
+alter table crawling_task add render_timeout_ms int null;
+alter table crawling_task add render_max_tabs int null;
+alter table crawling_task add count_distinct_content boolean default false not null;
+alter table crawling_task add trailing_slash varchar(16) null comment 'keep, add or remove, keep if null';
+alter table crawling_task add check_links_workers int null;
+alter table crawling_task add check_links_rps double null;
+*/
+
+// Columns of the crawling task table in the order of scanCrawlingTask
+const CRAWLING_TASK_COLUMNS = "id, id_estimator, url, include_subdomains, exceptions, allowances, status, hidden, " +
+	"ignore_robots, workers, requests_per_second, max_in_flight_per_host, " +
+	"max_depth, max_pages, max_pages_per_host, max_duration_ms, max_bytes, stop_reason, " +
+	"nofollow_policy, check_links, allowed_mime_types, max_body_size, " +
+	"transfer_bytes, decoded_bytes, renderer, render_wait, render_timeout_ms, " +
+	"render_max_tabs, count_distinct_content, trailing_slash, " +
+	"check_links_workers, check_links_rps"
+
 type CrawlingTask struct {
-	Id                int            `json:"id"`
-	IdEstimator       int            `json:"idEstimator"`
-	Url               string         `json:"url"`
-	IncludeSubdomains bool           `json:"includeSubdomains"`
-	Exceptions        sql.NullString `json:"exceptions"`
-	Allowances        sql.NullString `json:"allowances"`
-	Status            string         `json:"status"`
-	Hidden            bool           `json:"hidden"`
+	Id                 int             `json:"id"`
+	IdEstimator        int             `json:"idEstimator"`
+	Url                string          `json:"url"`
+	IncludeSubdomains  bool            `json:"includeSubdomains"`
+	Exceptions         sql.NullString  `json:"exceptions"`
+	Allowances         sql.NullString  `json:"allowances"`
+	Status             string          `json:"status"`
+	Hidden             bool            `json:"hidden"`
+	IgnoreRobots       bool            `json:"ignoreRobots"`
+	Workers            sql.NullInt64   `json:"workers"`
+	RequestsPerSecond  sql.NullFloat64 `json:"requestsPerSecond"`
+	MaxInFlightPerHost sql.NullInt64   `json:"maxInFlightPerHost"`
+	MaxDepth           sql.NullInt64   `json:"maxDepth"`
+	MaxPages           sql.NullInt64   `json:"maxPages"`
+	MaxPagesPerHost    sql.NullInt64   `json:"maxPagesPerHost"`
+	MaxDurationMs      sql.NullInt64   `json:"maxDurationMs"`
+	MaxBytes           sql.NullInt64   `json:"maxBytes"`
+	StopReason         sql.NullString  `json:"stopReason"` // comma separated STOP_REASON_* which stopped the crawling
+	NoFollowPolicy     sql.NullString  `json:"noFollowPolicy"`
+	CheckLinks         bool            `json:"checkLinks"`       // check assets and external links after the crawling
+	AllowedMimeTypes   sql.NullString  `json:"allowedMimeTypes"` // comma separated mime types of the pages to parse
+	MaxBodySize        sql.NullInt64   `json:"maxBodySize"`
+	TransferBytes      sql.NullInt64   `json:"transferBytes"` // bytes transferred by the crawling
+	DecodedBytes       sql.NullInt64   `json:"decodedBytes"`  // bytes of the decompressed bodies
+	Renderer           sql.NullString  `json:"renderer"`
+	RenderWait         sql.NullString  `json:"renderWait"`
+	RenderTimeoutMs    sql.NullInt64   `json:"renderTimeoutMs"`
+	RenderMaxTabs      sql.NullInt64   `json:"renderMaxTabs"`
+	// Estimate only the first page of the duplicates
+	CountDistinctContent bool           `json:"countDistinctContent"`
+	TrailingSlash        sql.NullString `json:"trailingSlash"`
+	// Politeness of the links checking, see CheckLinks
+	CheckLinksWorkers sql.NullInt64   `json:"checkLinksWorkers"`
+	CheckLinksRps     sql.NullFloat64 `json:"checkLinksRps"`
 }
 
 type Estimation struct {
@@ -51,6 +126,197 @@ type Estimation struct {
 	ResultsLink     string         `json:"resultsLink"`
 }
 
+// Common interface of *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+/*
+create table crawl_coverage
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	link varchar(2000) not null,
+	kind varchar(32) not null,
+	details varchar(2000) null,
+	constraint crawl_coverage_pk
+		primary key (id)
+);
+*/
+
+type CrawlCoverage struct {
+	Id             int            `json:"id"`
+	CrawlingTaskId int            `json:"crawlingTaskId"`
+	Link           string         `json:"link"`
+	Kind           string         `json:"kind"`
+	Details        sql.NullString `json:"details"`
+}
+
+/*
+create table crawling_task_rule
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	name varchar(255) not null,
+	type varchar(16) not null comment 'css, xpath or regex',
+	expression text not null,
+	multiple tinyint(1) default 0 not null,
+	constraint crawling_task_rule_pk
+		primary key (id)
+);
+*/
+
+type CrawlingTaskRule struct {
+	Id             int    `json:"id"`
+	CrawlingTaskId int    `json:"crawlingTaskId"`
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Expression     string `json:"expression"`
+	Multiple       bool   `json:"multiple"`
+}
+
+/*
+create table crawled_link_data
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	link varchar(2000) not null,
+	name varchar(255) not null,
+	value text not null,
+	constraint crawled_link_data_pk
+		primary key (id)
+);
+*/
+
+// Value scraped from the crawled link by the rule with the name, multiple values are separate rows
+type CrawledLinkData struct {
+	Id             int    `json:"id"`
+	CrawlingTaskId int    `json:"crawlingTaskId"`
+	Link           string `json:"link"`
+	Name           string `json:"name"`
+	Value          string `json:"value"`
+}
+
+/*
+create table crawl_asset
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	url varchar(2000) not null,
+	type varchar(16) not null,
+	pages_num int not null,
+	constraint crawl_asset_pk
+		primary key (id)
+);
+*/
+
+// Unique asset of the crawled site with the number of pages using it
+type CrawlAsset struct {
+	Id             int    `json:"id"`
+	CrawlingTaskId int    `json:"crawlingTaskId"`
+	Url            string `json:"url"`
+	Type           string `json:"type"`
+	PagesNum       int    `json:"pagesNum"`
+}
+
+/*
+create table crawl_broken_resource
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	page_url varchar(2000) not null,
+	url varchar(2000) not null,
+	type varchar(16) not null,
+	status_code int null,
+	error varchar(2000) null,
+	constraint crawl_broken_resource_pk
+		primary key (id)
+);
+*/
+
+// Broken asset or external link of the crawled page
+type BrokenResource struct {
+	Id             int            `json:"id"`
+	CrawlingTaskId int            `json:"crawlingTaskId"`
+	PageUrl        string         `json:"pageUrl"`
+	Url            string         `json:"url"`
+	Type           string         `json:"type"`
+	StatusCode     sql.NullInt64  `json:"statusCode"`
+	Error          sql.NullString `json:"error"`
+}
+
+/*
+create table crawl_redirect
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	url varchar(2000) not null,
+	final_url varchar(2000) null,
+	kind varchar(16) not null,
+	hops int not null,
+	chain text not null,
+	constraint crawl_redirect_pk
+		primary key (id)
+);
+*/
+
+// Long, cross domain or looped redirect chain of the crawled page
+type CrawlRedirect struct {
+	Id             int            `json:"id"`
+	CrawlingTaskId int            `json:"crawlingTaskId"`
+	Url            string         `json:"url"`
+	FinalUrl       sql.NullString `json:"finalUrl"`
+	Kind           string         `json:"kind"`
+	Hops           int            `json:"hops"`
+	Chain          string         `json:"chain"` // json array of the redirect hops
+}
+
+/*
+create table crawl_heavy_page
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	url varchar(2000) not null,
+	transfer_size bigint not null,
+	size bigint not null,
+	encoding varchar(16) not null,
+	constraint crawl_heavy_page_pk
+		primary key (id)
+);
+*/
+
+// Crawled page with the most bytes transferred
+type CrawlHeavyPage struct {
+	Id             int    `json:"id"`
+	CrawlingTaskId int    `json:"crawlingTaskId"`
+	Url            string `json:"url"`
+	TransferSize   int64  `json:"transferSize"`
+	Size           int64  `json:"size"`
+	Encoding       string `json:"encoding"`
+}
+
+/*
+create table crawl_duplicate
+(
+	id int not null AUTO_INCREMENT,
+	crawling_task_id int not null,
+	group_num int not null,
+	kind varchar(16) not null,
+	url varchar(2000) not null,
+	constraint crawl_duplicate_pk
+		primary key (id)
+);
+*/
+
+// Page of the group with the same or nearly the same main text
+type CrawlDuplicate struct {
+	Id             int    `json:"id"`
+	CrawlingTaskId int    `json:"crawlingTaskId"`
+	GroupNum       int    `json:"groupNum"` // number of the group within the crawling task
+	Kind           string `json:"kind"`     // exact or near
+	Url            string `json:"url"`
+}
+
 type DBCredentials struct {
 	Username    string `json:"username"`
 	Password    string `json:"password"`
@@ -125,15 +391,13 @@ func GetConnection() (conn *sql.DB, err error) {
 func GetActiveTasks(conn *sql.DB) (activeTasks []CrawlingTask, err error) {
 	// Select all active tasks
 	activeTasks = make([]CrawlingTask, 0)
-	tasks, err := conn.Query("SELECT * FROM " + CRAWLING_TASK_TABLE + " WHERE `hidden` IS FALSE")
+	tasks, err := conn.Query("SELECT " + CRAWLING_TASK_COLUMNS + " FROM " + CRAWLING_TASK_TABLE + " WHERE `hidden` IS FALSE")
 	if err != nil {
 		return nil, err
 	}
 	// Map data to CrawlingTask objects
 	for tasks.Next() {
-		task := CrawlingTask{}
-		err = tasks.Scan(&task.Id, &task.IdEstimator, &task.Url, &task.IncludeSubdomains,
-			&task.Exceptions, &task.Allowances, &task.Status, &task.Hidden)
+		task, err := scanCrawlingTask(tasks)
 		if err != nil {
 			return nil, err
 		}
@@ -148,22 +412,46 @@ func GetActiveTasks(conn *sql.DB) (activeTasks []CrawlingTask, err error) {
 	return activeTasks, nil
 }
 
+// Returns the crawling task by id regardless of it's status and visibility
+// or sql.ErrNoRows if the task doesn't exist anymore
+func GetCrawlingTaskById(id int, conn *sql.DB) (task CrawlingTask, err error) {
+	row := conn.QueryRow("SELECT "+CRAWLING_TASK_COLUMNS+" FROM "+CRAWLING_TASK_TABLE+" WHERE id=?", id)
+
+	return scanCrawlingTask(row)
+}
+
+// Maps a row of the crawling task table to CrawlingTask object.
+// Columns order is CRAWLING_TASK_COLUMNS
+func scanCrawlingTask(row rowScanner) (task CrawlingTask, err error) {
+	err = row.Scan(&task.Id, &task.IdEstimator, &task.Url, &task.IncludeSubdomains,
+		&task.Exceptions, &task.Allowances, &task.Status, &task.Hidden, &task.IgnoreRobots,
+		&task.Workers, &task.RequestsPerSecond, &task.MaxInFlightPerHost,
+		&task.MaxDepth, &task.MaxPages, &task.MaxPagesPerHost, &task.MaxDurationMs, &task.MaxBytes, &task.StopReason,
+		&task.NoFollowPolicy, &task.CheckLinks, &task.AllowedMimeTypes, &task.MaxBodySize,
+		&task.TransferBytes, &task.DecodedBytes, &task.Renderer, &task.RenderWait, &task.RenderTimeoutMs,
+		&task.RenderMaxTabs, &task.CountDistinctContent, &task.TrailingSlash,
+		&task.CheckLinksWorkers, &task.CheckLinksRps)
+	if err != nil {
+		return CrawlingTask{}, err
+	}
+
+	return task, nil
+}
+
+// Updates the columns owned by the task tracker: the status and the results of the crawling.
+// The settings and the visibility are edited by the web gui while the task is crawled, so they are kept
 func UpdateCrawlingTaskById(task CrawlingTask, conn *sql.DB) (err error) {
 	stmt, err := conn.Prepare("UPDATE " + CRAWLING_TASK_TABLE + " SET " +
-		"id_estimator=?, " +
-		"url=?, " +
-		"include_subdomains=?, " +
-		"exceptions=?, " +
-		"allowances=?, " +
 		"status=?, " +
-		"hidden=? " +
+		"stop_reason=?, " +
+		"transfer_bytes=?, " +
+		"decoded_bytes=? " +
 		"WHERE id=?")
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(task.IdEstimator, task.Url, task.IncludeSubdomains,
-		task.Exceptions, task.Allowances, task.Status, task.Hidden, task.Id)
+	_, err = stmt.Exec(task.Status, task.StopReason, task.TransferBytes, task.DecodedBytes, task.Id)
 	if err != nil {
 		return err
 	}
@@ -289,3 +577,129 @@ func InsertIntoCrawledLinkEstimation(linkEstimations []CrawledLinkEstimation, co
 
 	return nil
 }
+
+func InsertIntoCrawlCoverage(coverage []CrawlCoverage, conn *sql.DB) (err error) {
+	rows := make([][]interface{}, 0, len(coverage))
+	for _, c := range coverage {
+		rows = append(rows, []interface{}{c.CrawlingTaskId, c.Link, c.Kind, c.Details})
+	}
+
+	return batchInsert(CRAWL_COVERAGE_TABLE, []string{"crawling_task_id", "link", "kind", "details"}, rows, conn)
+}
+
+func GetCrawlingTaskRules(taskId int, conn *sql.DB) (rules []CrawlingTaskRule, err error) {
+	rules = make([]CrawlingTaskRule, 0)
+	rows, err := conn.Query("SELECT `id`, `crawling_task_id`, `name`, `type`, `expression`, `multiple` FROM "+
+		CRAWLING_TASK_RULE_TABLE+" WHERE `crawling_task_id`=? ORDER BY `id`", taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule CrawlingTaskRule
+		err = rows.Scan(&rule.Id, &rule.CrawlingTaskId, &rule.Name, &rule.Type, &rule.Expression, &rule.Multiple)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func InsertIntoCrawledLinkData(data []CrawledLinkData, conn *sql.DB) (err error) {
+	rows := make([][]interface{}, 0, len(data))
+	for _, d := range data {
+		rows = append(rows, []interface{}{d.CrawlingTaskId, d.Link, d.Name, d.Value})
+	}
+
+	return batchInsert(CRAWLED_LINK_DATA_TABLE, []string{"crawling_task_id", "link", "name", "value"}, rows, conn)
+}
+
+// Inserts the rows by the statements of BATCH_INSERT_SIZE rows at most in a single transaction,
+// the values of the rows go in the order of the columns
+func batchInsert(table string, columns []string, rows [][]interface{}, conn *sql.DB) (err error) {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	batchInsertHeader := "INSERT INTO " + table + " (`" + strings.Join(columns, "`, `") + "`) VALUES "
+	placeholders := "(" + strings.Repeat("?, ", len(columns)-1) + "?)"
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(rows); start += BATCH_INSERT_SIZE {
+		end := start + BATCH_INSERT_SIZE
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		batch := make([]string, 0, end-start)
+		args := make([]interface{}, 0, len(columns)*(end-start))
+		for _, row := range rows[start:end] {
+			batch = append(batch, placeholders)
+			args = append(args, row...)
+		}
+
+		_, err = tx.Exec(batchInsertHeader+strings.Join(batch, ", "), args...)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func InsertIntoCrawlAsset(assets []CrawlAsset, conn *sql.DB) (err error) {
+	rows := make([][]interface{}, 0, len(assets))
+	for _, a := range assets {
+		rows = append(rows, []interface{}{a.CrawlingTaskId, a.Url, a.Type, a.PagesNum})
+	}
+
+	return batchInsert(CRAWL_ASSET_TABLE, []string{"crawling_task_id", "url", "type", "pages_num"}, rows, conn)
+}
+
+func InsertIntoBrokenResource(resources []BrokenResource, conn *sql.DB) (err error) {
+	rows := make([][]interface{}, 0, len(resources))
+	for _, r := range resources {
+		rows = append(rows, []interface{}{r.CrawlingTaskId, r.PageUrl, r.Url, r.Type, r.StatusCode, r.Error})
+	}
+
+	return batchInsert(BROKEN_RESOURCE_TABLE,
+		[]string{"crawling_task_id", "page_url", "url", "type", "status_code", "error"}, rows, conn)
+}
+
+func InsertIntoCrawlRedirect(redirects []CrawlRedirect, conn *sql.DB) (err error) {
+	rows := make([][]interface{}, 0, len(redirects))
+	for _, r := range redirects {
+		rows = append(rows, []interface{}{r.CrawlingTaskId, r.Url, r.FinalUrl, r.Kind, r.Hops, r.Chain})
+	}
+
+	return batchInsert(CRAWL_REDIRECT_TABLE,
+		[]string{"crawling_task_id", "url", "final_url", "kind", "hops", "chain"}, rows, conn)
+}
+
+func InsertIntoCrawlHeavyPage(pages []CrawlHeavyPage, conn *sql.DB) (err error) {
+	rows := make([][]interface{}, 0, len(pages))
+	for _, p := range pages {
+		rows = append(rows, []interface{}{p.CrawlingTaskId, p.Url, p.TransferSize, p.Size, p.Encoding})
+	}
+
+	return batchInsert(CRAWL_HEAVY_PAGE_TABLE,
+		[]string{"crawling_task_id", "url", "transfer_size", "size", "encoding"}, rows, conn)
+}
+
+func InsertIntoCrawlDuplicate(duplicates []CrawlDuplicate, conn *sql.DB) (err error) {
+	rows := make([][]interface{}, 0, len(duplicates))
+	for _, d := range duplicates {
+		rows = append(rows, []interface{}{d.CrawlingTaskId, d.GroupNum, d.Kind, d.Url})
+	}
+
+	return batchInsert(CRAWL_DUPLICATE_TABLE, []string{"crawling_task_id", "group_num", "kind", "url"}, rows, conn)
+}
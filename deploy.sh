@@ -1,3 +1,6 @@
 #!/usr/bin/bash
 go get github.com/PuerkitoBio/goquery
-go get github.com/go-sql-driver/mysql
\ No newline at end of file
+go get github.com/go-sql-driver/mysql
+go get github.com/andybalholm/brotli
+go get github.com/antchfx/htmlquery
+go get github.com/chromedp/chromedp
\ No newline at end of file
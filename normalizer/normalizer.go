@@ -0,0 +1,274 @@
+package normalizer
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Trailing slash policies
+const (
+	TRAILING_SLASH_KEEP   = "keep"   // leave the path as it is
+	TRAILING_SLASH_ADD    = "add"    // add '/' to the paths not ending with a file name
+	TRAILING_SLASH_REMOVE = "remove" // remove '/' from the end of not root paths
+)
+
+// Tracking query parameters, '*' at the end matches any suffix
+var DEFAULT_TRACKING_PARAMS = []string{"utm_*", "fbclid", "gclid", "yclid", "msclkid", "mc_cid", "mc_eid"}
+
+// Turns different spellings of the same url into the one canonical form
+type Normalizer interface {
+	Normalize(link string) (string, error)
+}
+
+// Normalization steps, each of them can be switched off
+type Rules struct {
+	LowercaseSchemeAndHost bool     `json:"lowercaseSchemeAndHost"`
+	RemoveDefaultPort      bool     `json:"removeDefaultPort"`   // :80 for http, :443 for https
+	NormalizeEscapes       bool     `json:"normalizeEscapes"`    // decode unreserved chars, uppercase hex digits
+	RemoveDotSegments      bool     `json:"removeDotSegments"`   // /a/./b/../c -> /a/c
+	RemoveFragment         bool     `json:"removeFragment"`      // #...
+	SortQuery              bool     `json:"sortQuery"`           // ?b=1&a=2 -> ?a=2&b=1
+	StripTrackingParams    bool     `json:"stripTrackingParams"` // remove TrackingParams from the query
+	TrackingParams         []string `json:"trackingParams"`      // DEFAULT_TRACKING_PARAMS if empty
+	TrailingSlash          string   `json:"trailingSlash"`       // one of TRAILING_SLASH_*, keep if empty
+}
+
+// All the rules except of changing the trailing slash
+func DefaultRules() Rules {
+	return Rules{
+		LowercaseSchemeAndHost: true,
+		RemoveDefaultPort:      true,
+		NormalizeEscapes:       true,
+		RemoveDotSegments:      true,
+		RemoveFragment:         true,
+		SortQuery:              true,
+		StripTrackingParams:    true,
+		TrackingParams:         DEFAULT_TRACKING_PARAMS,
+		TrailingSlash:          TRAILING_SLASH_KEEP,
+	}
+}
+
+// Normalizer applying the rules
+type RulesNormalizer struct {
+	Rules Rules
+}
+
+func NewNormalizer(rules Rules) RulesNormalizer {
+	return RulesNormalizer{Rules: rules}
+}
+
+// Normalizer with DefaultRules
+var Default Normalizer = NewNormalizer(DefaultRules())
+
+func (n RulesNormalizer) Normalize(link string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return "", errors.New("Not absolute url: \"" + link + "\"")
+	}
+	r := n.Rules
+
+	if r.LowercaseSchemeAndHost {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+	}
+
+	if r.RemoveDefaultPort {
+		if port := u.Port(); (port == "80" && u.Scheme == "http") || (port == "443" && u.Scheme == "https") {
+			u.Host = strings.TrimSuffix(u.Host, ":"+port)
+		}
+	}
+
+	escapedPath := u.EscapedPath()
+	if escapedPath == "" {
+		escapedPath = "/"
+	}
+	if r.NormalizeEscapes {
+		escapedPath = normalizeEscapes(escapedPath)
+	}
+	if r.RemoveDotSegments {
+		escapedPath = removeDotSegments(escapedPath)
+	}
+	escapedPath = applyTrailingSlash(escapedPath, r.TrailingSlash)
+	if err = setEscapedPath(u, escapedPath); err != nil {
+		return "", err
+	}
+
+	if r.StripTrackingParams || r.SortQuery || r.NormalizeEscapes {
+		u.RawQuery = n.normalizeQuery(u.RawQuery)
+		u.ForceQuery = false
+	}
+
+	if r.RemoveFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	return u.String(), nil
+}
+
+func setEscapedPath(u *url.URL, escapedPath string) error {
+	unescaped, err := url.PathUnescape(escapedPath)
+	if err != nil {
+		return err
+	}
+	u.Path = unescaped
+	u.RawPath = escapedPath
+	if u.EscapedPath() != escapedPath { // RawPath is not a valid encoding of Path
+		u.RawPath = ""
+	}
+	return nil
+}
+
+// Query params are kept encoded as is, only their order and set are changed
+func (n RulesNormalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	params := make([]string, 0)
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		if n.Rules.NormalizeEscapes {
+			param = normalizeEscapes(param)
+		}
+		if n.Rules.StripTrackingParams && n.isTrackingParam(param) {
+			continue
+		}
+		params = append(params, param)
+	}
+	if n.Rules.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return paramName(params[i]) < paramName(params[j])
+		})
+	}
+
+	return strings.Join(params, "&")
+}
+
+func paramName(param string) string {
+	return strings.SplitN(param, "=", 2)[0]
+}
+
+func (n RulesNormalizer) isTrackingParam(param string) bool {
+	name, err := url.QueryUnescape(paramName(param))
+	if err != nil {
+		return false
+	}
+	name = strings.ToLower(name)
+
+	trackingParams := n.Rules.TrackingParams
+	if len(trackingParams) == 0 {
+		trackingParams = DEFAULT_TRACKING_PARAMS
+	}
+	for _, tracking := range trackingParams {
+		if strings.HasSuffix(tracking, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(tracking, "*")) {
+				return true
+			}
+		} else if name == tracking {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Decodes percent-encoded unreserved chars(RFC 3986 2.3) and uppercases hex digits of the rest
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteString(strings.ToUpper(s[i : i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// Removes '.' and '..' segments(RFC 3986 5.2.4), the trailing slash is kept
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+
+	segments := strings.Split(p, "/")
+	output := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				output = append(output, "")
+			}
+		case "..":
+			if len(output) > 1 {
+				output = output[:len(output)-1]
+			}
+			if last {
+				output = append(output, "")
+			}
+		default:
+			output = append(output, segment)
+		}
+	}
+
+	result := strings.Join(output, "/")
+	if !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
+
+func applyTrailingSlash(p string, policy string) string {
+	switch policy {
+	case TRAILING_SLASH_ADD:
+		if !strings.HasSuffix(p, "/") && !strings.Contains(path.Base(p), ".") {
+			return p + "/"
+		}
+	case TRAILING_SLASH_REMOVE:
+		if p != "/" {
+			return strings.TrimSuffix(p, "/")
+		}
+	}
+	return p
+}
@@ -0,0 +1,82 @@
+package normalizer
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     string
+		expected string
+	}{
+		{"lowercase scheme and host", "HTTPS://Example.COM/Path", "https://example.com/Path"},
+		{"http default port", "http://example.com:80/a", "http://example.com/a"},
+		{"https default port", "https://example.com:443/a", "https://example.com/a"},
+		{"not default port", "https://example.com:8080/a", "https://example.com:8080/a"},
+		{"empty path", "https://example.com", "https://example.com/"},
+		{"unreserved escapes", "https://example.com/%7Euser/%61", "https://example.com/~user/a"},
+		{"reserved escapes uppercased", "https://example.com/a%2fb?q=%3d", "https://example.com/a%2Fb?q=%3D"},
+		{"dot segments", "https://example.com/a/./b/../c", "https://example.com/a/c"},
+		{"dot segments with trailing slash", "https://example.com/a/b/..", "https://example.com/a/"},
+		{"dot segments above root", "https://example.com/../a", "https://example.com/a"},
+		{"fragment", "https://example.com/a#top", "https://example.com/a"},
+		{"query sort", "https://example.com/?b=2&a=1&c", "https://example.com/?a=1&b=2&c"},
+		{"tracking params", "https://example.com/?utm_source=x&id=1&fbclid=y&GCLID=z", "https://example.com/?id=1"},
+		{"only tracking params", "https://example.com/a?utm_medium=x", "https://example.com/a"},
+		{"trailing slash is kept", "https://example.com/a/", "https://example.com/a/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalized, err := Default.Normalize(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if normalized != tt.expected {
+				t.Errorf("Normalize(%q) = %q, expected %q", tt.link, normalized, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	tests := []struct {
+		policy   string
+		link     string
+		expected string
+	}{
+		{TRAILING_SLASH_KEEP, "https://example.com/a", "https://example.com/a"},
+		{TRAILING_SLASH_KEEP, "https://example.com/a/", "https://example.com/a/"},
+		{"", "https://example.com/a", "https://example.com/a"},
+		{TRAILING_SLASH_ADD, "https://example.com/a", "https://example.com/a/"},
+		{TRAILING_SLASH_ADD, "https://example.com/a/?q=1", "https://example.com/a/?q=1"},
+		{TRAILING_SLASH_ADD, "https://example.com/a/page.html", "https://example.com/a/page.html"},
+		{TRAILING_SLASH_REMOVE, "https://example.com/a/", "https://example.com/a"},
+		{TRAILING_SLASH_REMOVE, "https://example.com/a", "https://example.com/a"},
+		{TRAILING_SLASH_REMOVE, "https://example.com/", "https://example.com/"},
+	}
+	for _, tt := range tests {
+		rules := DefaultRules()
+		rules.TrailingSlash = tt.policy
+		normalized, err := NewNormalizer(rules).Normalize(tt.link)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if normalized != tt.expected {
+			t.Errorf("%q policy: Normalize(%q) = %q, expected %q", tt.policy, tt.link, normalized, tt.expected)
+		}
+	}
+}
+
+func TestRulesSwitchedOff(t *testing.T) {
+	link := "HTTP://Example.com:80/a/../%7eb?utm_source=x&b=1&a=2#top"
+	normalized, err := NewNormalizer(Rules{}).Normalize(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if normalized != "http://Example.com:80/a/../%7eb?utm_source=x&b=1&a=2#top" {
+		t.Errorf("Normalize(%q) = %q", link, normalized)
+	}
+
+	if _, err = Default.Normalize("/relative/path"); err == nil {
+		t.Errorf("relative url is normalized")
+	}
+}
@@ -0,0 +1,208 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ANY_USER_AGENT = "*"
+	MAX_SIZE       = 500 * 1024 // robots.txt bytes to parse, the rest is ignored (RFC 9309)
+)
+
+type Rule struct {
+	Allow   bool   `json:"allow"`
+	Pattern string `json:"pattern"`
+	regexp  *regexp.Regexp
+}
+
+// Matches path with the rule pattern. '*' matches any sequence of chars, '$' at the end anchors the end of path
+func (r Rule) Matches(path string) bool {
+	return r.regexp.MatchString(path)
+}
+
+// Rules which are applied to the listed user agents
+type Group struct {
+	UserAgents []string      `json:"userAgents"`
+	Rules      []Rule        `json:"rules"`
+	CrawlDelay time.Duration `json:"crawlDelay"` // 0 if not specified
+}
+
+// Parsed robots.txt
+type Robots struct {
+	Groups   []Group  `json:"groups"`
+	Sitemaps []string `json:"sitemaps"`
+}
+
+// Robots which allow everything
+func AllowAll() Robots {
+	return Robots{}
+}
+
+// Robots which disallow everything
+func DisallowAll() Robots {
+	return Robots{Groups: []Group{{
+		UserAgents: []string{ANY_USER_AGENT},
+		Rules:      []Rule{newRule(false, "/")},
+	}}}
+}
+
+func newRule(allow bool, pattern string) Rule {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, `.*`, -1)
+	if strings.HasSuffix(expr, `\$`) {
+		expr = strings.TrimSuffix(expr, `\$`) + `$`
+	}
+
+	return Rule{Allow: allow, Pattern: pattern, regexp: regexp.MustCompile(`^` + expr)}
+}
+
+// Parses robots.txt content. Unknown lines and lines without ':' are skipped
+func Parse(r io.Reader) Robots {
+	robots := Robots{}
+	var group *Group
+	groupHasRules := false // user-agent line after rules starts a new group
+
+	scanner := bufio.NewScanner(io.LimitReader(r, MAX_SIZE))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Remove comments
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		keyValue := strings.SplitN(line, ":", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(keyValue[0]))
+		value := strings.TrimSpace(keyValue[1])
+
+		switch key {
+		case "user-agent":
+			if group == nil || groupHasRules {
+				robots.Groups = append(robots.Groups, Group{})
+				group = &robots.Groups[len(robots.Groups)-1]
+				groupHasRules = false
+			}
+			group.UserAgents = append(group.UserAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			if group == nil {
+				continue
+			}
+			groupHasRules = true
+			if value == "" { // empty disallow allows everything
+				continue
+			}
+			group.Rules = append(group.Rules, newRule(key == "allow", value))
+		case "crawl-delay":
+			if group == nil {
+				continue
+			}
+			groupHasRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				group.CrawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap": // not bound to the groups
+			if value != "" {
+				robots.Sitemaps = append(robots.Sitemaps, value)
+			}
+		}
+	}
+
+	return robots
+}
+
+// Returns the product token of the User-Agent header the groups are matched against: the first product
+// of the "compatible" comment or the first product of the header, e.g. "go-crawler" of
+// "Mozilla/5.0 (compatible; go-crawler/1.0)"
+func ProductToken(userAgent string) string {
+	userAgent = strings.ToLower(userAgent)
+	if i := strings.Index(userAgent, "compatible;"); i >= 0 {
+		userAgent = userAgent[i+len("compatible;"):]
+	}
+
+	return identifier(strings.TrimSpace(userAgent))
+}
+
+// Returns the leading chars allowed in the product tokens: letters, '-' and '_' (RFC 9309)
+func identifier(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_')
+	})
+	if end >= 0 {
+		return s[:end]
+	}
+
+	return s
+}
+
+// Returns the groups for the userAgent: the groups naming its product token case-insensitively
+// or '*' groups if there are no such
+func (r Robots) groupsFor(userAgent string) (groups []Group) {
+	token := ProductToken(userAgent)
+
+	for _, g := range r.Groups {
+		for _, ua := range g.UserAgents {
+			if ua != ANY_USER_AGENT && token != "" && identifier(ua) == token {
+				groups = append(groups, g)
+				break
+			}
+		}
+	}
+	if len(groups) > 0 {
+		return groups
+	}
+
+	for _, g := range r.Groups {
+		for _, ua := range g.UserAgents {
+			if ua == ANY_USER_AGENT {
+				groups = append(groups, g)
+				break
+			}
+		}
+	}
+
+	return groups
+}
+
+// Checks the path(with the query) against the rules for userAgent.
+// The longest matched rule wins, allow wins on equal length
+func (r Robots) IsAllowed(userAgent string, path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+
+	var best *Rule
+	for _, g := range r.groupsFor(userAgent) {
+		for i, rule := range g.Rules {
+			if !rule.Matches(path) {
+				continue
+			}
+			if best == nil || len(rule.Pattern) > len(best.Pattern) ||
+				(len(rule.Pattern) == len(best.Pattern) && rule.Allow) {
+				best = &g.Rules[i]
+			}
+		}
+	}
+
+	return best == nil || best.Allow
+}
+
+// Returns the Crawl-delay for userAgent, 0 if not specified
+func (r Robots) CrawlDelay(userAgent string) (delay time.Duration) {
+	for _, g := range r.groupsFor(userAgent) {
+		if g.CrawlDelay > delay {
+			delay = g.CrawlDelay
+		}
+	}
+
+	return delay
+}
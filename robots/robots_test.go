@@ -0,0 +1,101 @@
+package robots
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `# comment
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow: /search*q=
+Crawl-delay: 2
+
+User-agent: Go-Crawler
+User-agent: other-bot
+Disallow: /no-crawler
+Allow: /page
+Disallow: /page
+Crawl-delay: 0.5
+
+User-agent: go
+Disallow: /
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestIsAllowed(t *testing.T) {
+	r := Parse(strings.NewReader(testRobots))
+
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		allowed   bool
+	}{
+		{"any agent", "SomeBot/1.0", "/", true},
+		{"disallowed dir", "SomeBot/1.0", "/private/page", false},
+		{"longer allow wins", "SomeBot/1.0", "/private/public/page", true},
+		{"wildcard and end anchor", "SomeBot/1.0", "/files/doc.pdf", false},
+		{"end anchor", "SomeBot/1.0", "/files/doc.pdf?download=1", true},
+		{"wildcard in the middle", "SomeBot/1.0", "/search/all?q=go", false},
+		{"robots.txt", "SomeBot/1.0", "/robots.txt", true},
+		{"product token of compatible", "Mozilla/5.0 (compatible; go-crawler/1.0)", "/private/page", true},
+		{"product token", "GO-CRAWLER/2.0", "/no-crawler/page", false},
+		{"allow wins on equal length", "go-crawler", "/page", true},
+		{"token is not a substring", "Mozilla/5.0 (compatible; go-crawler-next/1.0)", "/no-crawler", true},
+		{"group of short token", "Go/1.1", "/page", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed := r.IsAllowed(tt.userAgent, tt.path); allowed != tt.allowed {
+				t.Errorf("IsAllowed(%q, %q) = %v, expected %v", tt.userAgent, tt.path, allowed, tt.allowed)
+			}
+		})
+	}
+
+	if len(r.Sitemaps) != 1 || r.Sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("sitemaps = %v", r.Sitemaps)
+	}
+}
+
+func TestCrawlDelay(t *testing.T) {
+	r := Parse(strings.NewReader(testRobots))
+
+	tests := []struct {
+		userAgent string
+		delay     time.Duration
+	}{
+		{"SomeBot/1.0", 2 * time.Second},
+		{"Mozilla/5.0 (compatible; go-crawler/1.0)", 500 * time.Millisecond},
+		{"Go/1.1", 0},
+	}
+	for _, tt := range tests {
+		if delay := r.CrawlDelay(tt.userAgent); delay != tt.delay {
+			t.Errorf("CrawlDelay(%q) = %v, expected %v", tt.userAgent, delay, tt.delay)
+		}
+	}
+}
+
+func TestProductToken(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (compatible; go-crawler/1.0)":                         "go-crawler",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/)": "googlebot",
+		"My_Bot/3.0 (+https://example.com/bot)":                            "my_bot",
+		"":                                                                 "",
+	}
+	for userAgent, expected := range tests {
+		if token := ProductToken(userAgent); token != expected {
+			t.Errorf("ProductToken(%q) = %q, expected %q", userAgent, token, expected)
+		}
+	}
+}
+
+func TestDisallowAll(t *testing.T) {
+	if DisallowAll().IsAllowed("go-crawler", "/page") || !AllowAll().IsAllowed("go-crawler", "/page") {
+		t.Errorf("unexpected rules of DisallowAll or AllowAll")
+	}
+}
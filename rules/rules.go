@@ -0,0 +1,208 @@
+package rules
+
+import (
+	"errors"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"go-crawler/crawler"
+	"golang.org/x/net/html"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Rule types
+const (
+	TYPE_CSS   = "css"   // css selector, text of the element or its attribute with "@attr" suffix
+	TYPE_XPATH = "xpath" // xpath expression, text of the node(use /@attr to select attributes)
+	TYPE_REGEX = "regex" // regexp on the html source, the first group or the whole match
+)
+
+// Key of the rules values in CrawledPage.Extra
+const EXTRA_NAME = "rules"
+
+// Attribute suffix of css rules: meta[name=author]@content
+var CSS_ATTR_REGEXP = regexp.MustCompile(`^(.+)@([\w:-]+)$`)
+
+// Scraping rule, e.g. price = css ".price"
+type Rule struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"` // one of TYPE_*
+	Expression string `json:"expression"`
+	Multiple   bool   `json:"multiple"` // all the matches instead of the first one
+}
+
+// Rule ready to be applied
+type compiledRule struct {
+	Rule
+	css   cascadia.SelectorGroup
+	attr  string
+	xpath *xpath.Expr
+	regex *regexp.Regexp
+}
+
+func compile(rule Rule) (compiled compiledRule, err error) {
+	compiled.Rule = rule
+	if strings.TrimSpace(rule.Name) == "" {
+		return compiled, errors.New("Empty rule name")
+	}
+
+	switch rule.Type {
+	case TYPE_CSS:
+		selector := rule.Expression
+		if matched := CSS_ATTR_REGEXP.FindStringSubmatch(selector); matched != nil {
+			selector, compiled.attr = matched[1], matched[2]
+		}
+		compiled.css, err = cascadia.ParseGroup(selector)
+	case TYPE_XPATH:
+		compiled.xpath, err = xpath.Compile(rule.Expression)
+	case TYPE_REGEX:
+		compiled.regex, err = regexp.Compile(rule.Expression)
+	default:
+		err = errors.New("Unknown rule type: \"" + rule.Type + "\"")
+	}
+
+	return compiled, err
+}
+
+// Applies the rule to the page. Returns string for single rules, []string for multiple ones
+// and nil if nothing is matched
+func (r compiledRule) apply(root *html.Node, source []byte) any {
+	values := make([]string, 0)
+	add := func(value string) bool {
+		values = append(values, strings.TrimSpace(value))
+		return r.Multiple
+	}
+
+	switch r.Type {
+	case TYPE_CSS:
+		for _, node := range cascadia.QueryAll(root, r.css) {
+			value := htmlquery.InnerText(node)
+			if r.attr != "" {
+				if !htmlquery.ExistsAttr(node, r.attr) {
+					continue
+				}
+				value = htmlquery.SelectAttr(node, r.attr)
+			}
+			if !add(value) {
+				break
+			}
+		}
+	case TYPE_XPATH:
+		for _, node := range htmlquery.QuerySelectorAll(root, r.xpath) {
+			if !add(htmlquery.InnerText(node)) {
+				break
+			}
+		}
+	case TYPE_REGEX:
+		limit := 1
+		if r.Multiple {
+			limit = -1
+		}
+		for _, matched := range r.regex.FindAllSubmatch(source, limit) {
+			value := matched[0]
+			if len(matched) > 1 {
+				value = matched[1]
+			}
+			add(string(value))
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	if r.Multiple {
+		return values
+	}
+	return values[0]
+}
+
+// Applies the rules to the crawled pages. Values are stored in CrawledPage.Extra[EXTRA_NAME] by rule names
+type Extractor struct {
+	rules []compiledRule
+}
+
+// Broken rules are logged and skipped
+func NewExtractor(rules []Rule) Extractor {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		c, err := compile(rule)
+		if err == nil {
+			compiled = append(compiled, c)
+		} else {
+			log.Println("[rules]\tBroken rule: \"" + rule.Name + "\" with error: \"" + err.Error() + "\" skipping...")
+		}
+	}
+
+	return Extractor{rules: compiled}
+}
+
+func (e Extractor) Name() string { return EXTRA_NAME }
+
+func (e Extractor) Start(doc *crawler.Document) crawler.PageExtraction {
+	return &extraction{rules: e.rules, doc: doc}
+}
+
+// Applies the rules to the shared DOM of the page once the tokens are over
+type extraction struct {
+	rules []compiledRule
+	doc   *crawler.Document
+}
+
+func (e *extraction) UsesDom() bool { return len(e.rules) > 0 }
+
+func (e *extraction) Token(token html.Token) {}
+
+func (e *extraction) Finish(page *crawler.CrawledPage) (any, error) {
+	if len(e.rules) == 0 {
+		return nil, nil
+	}
+
+	root, err := e.doc.Node()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any)
+	for _, rule := range e.rules {
+		if value := rule.apply(root, e.doc.Source()); value != nil {
+			values[rule.Name] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	return values, nil
+}
+
+// Returns the rules values of the crawled pages by their final urls and rule names,
+// the values of the first page are kept if several pages are redirected to the same url
+func Values(levels []crawler.CrawledLevel) map[string]map[string][]string {
+	pagesValues := make(map[string]map[string][]string)
+	for _, lvl := range levels {
+		for _, page := range lvl.CrawledPages {
+			values, ok := page.Extra[EXTRA_NAME].(map[string]any)
+			if !ok || page.IsFailed() {
+				continue
+			}
+			if _, ok := pagesValues[page.FinalUrl]; ok {
+				continue
+			}
+
+			pageValues := make(map[string][]string, len(values))
+			for name, value := range values {
+				switch v := value.(type) {
+				case string:
+					pageValues[name] = []string{v}
+				case []string:
+					pageValues[name] = v
+				}
+			}
+			pagesValues[page.FinalUrl] = pageValues
+		}
+	}
+
+	return pagesValues
+}
@@ -0,0 +1,105 @@
+package rules
+
+import (
+	"context"
+	"go-crawler/crawler"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testProductHtml = `<html><head>
+<title>Product</title>
+<meta name="author" content="Jane Doe">
+</head><body>
+<h1>Coffee grinder</h1>
+<h2>Specs</h2>
+<span class="price"> $49.99 </span>
+<span class="price old">$59.99</span>
+<ul id="tags"><li>kitchen</li><li>coffee</li></ul>
+<p>SKU: CG-1024</p>
+</body></html>`
+
+func extractRules(t *testing.T, rules []Rule) map[string]any {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testProductHtml))
+	}))
+	defer srv.Close()
+
+	c := &crawler.Crawler{Extractors: append(crawler.DefaultExtractors(), NewExtractor(rules))}
+	page, err := c.ParsePage(context.Background(), srv.URL+"/product")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Title != "Product" {
+		t.Errorf("built-in extractors are broken, title %q", page.Title)
+	}
+
+	values, _ := page.Extra[EXTRA_NAME].(map[string]any)
+	return values
+}
+
+func TestRules(t *testing.T) {
+	values := extractRules(t, []Rule{
+		{Name: "price", Type: TYPE_CSS, Expression: ".price"},
+		{Name: "prices", Type: TYPE_CSS, Expression: ".price", Multiple: true},
+		{Name: "author", Type: TYPE_CSS, Expression: "meta[name=author]@content"},
+		{Name: "headings", Type: TYPE_CSS, Expression: "h1, h2", Multiple: true},
+		{Name: "sku", Type: TYPE_REGEX, Expression: `SKU:\s*([\w-]+)`},
+		{Name: "tags", Type: TYPE_XPATH, Expression: `//ul[@id="tags"]/li`, Multiple: true},
+		{Name: "author xpath", Type: TYPE_XPATH, Expression: `//meta[@name="author"]/@content`},
+		{Name: "missing", Type: TYPE_CSS, Expression: ".discount"},
+		{Name: "missing attr", Type: TYPE_CSS, Expression: "h1@title"},
+	})
+
+	expected := map[string]any{
+		"price":        "$49.99",
+		"prices":       []string{"$49.99", "$59.99"},
+		"author":       "Jane Doe",
+		"headings":     []string{"Coffee grinder", "Specs"},
+		"sku":          "CG-1024",
+		"tags":         []string{"kitchen", "coffee"},
+		"author xpath": "Jane Doe",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("values = %#v, expected %#v", values, expected)
+	}
+}
+
+func TestBrokenRules(t *testing.T) {
+	e := NewExtractor([]Rule{
+		{Name: "", Type: TYPE_CSS, Expression: "h1"},
+		{Name: "bad css", Type: TYPE_CSS, Expression: "h1["},
+		{Name: "bad xpath", Type: TYPE_XPATH, Expression: "//h1["},
+		{Name: "bad regex", Type: TYPE_REGEX, Expression: "("},
+		{Name: "unknown", Type: "jsonpath", Expression: "$.price"},
+		{Name: "title", Type: TYPE_CSS, Expression: "title"},
+	})
+	if len(e.rules) != 1 || e.rules[0].Name != "title" {
+		t.Errorf("compiled rules = %+v", e.rules)
+	}
+
+	if values := extractRules(t, nil); values != nil {
+		t.Errorf("values without rules = %v", values)
+	}
+}
+
+func TestValues(t *testing.T) {
+	levels := []crawler.CrawledLevel{{CrawledPages: []crawler.CrawledPage{
+		{FinalUrl: "http://example.com/a", Extra: map[string]any{EXTRA_NAME: map[string]any{
+			"price": "1", "tags": []string{"x", "y"}}}},
+		{FinalUrl: "http://example.com/a", Extra: map[string]any{EXTRA_NAME: map[string]any{"price": "2"}}},
+		{FinalUrl: "http://example.com/failed", ErrorKind: crawler.ERR_KIND_STATUS,
+			Extra: map[string]any{EXTRA_NAME: map[string]any{"price": "3"}}},
+		{FinalUrl: "http://example.com/none"},
+	}}}
+
+	expected := map[string]map[string][]string{
+		"http://example.com/a": {"price": {"1"}, "tags": {"x", "y"}},
+	}
+	if values := Values(levels); !reflect.DeepEqual(values, expected) {
+		t.Errorf("values = %v, expected %v", values, expected)
+	}
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"go-crawler/crawler"
+	"go-crawler/normalizer"
 	"go-crawler/utils"
 	"go-crawler/validator"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"time"
@@ -15,7 +19,74 @@ const (
 	LOG_FILENAME = "log.log"
 )
 
+// Repeatable string flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func main() {
+	// Fetcher settings
+	defFetcherConfig := crawler.DefaultFetcherConfig()
+	userAgent := flag.String("user-agent", defFetcherConfig.UserAgent, "User-Agent header")
+	requestTimeout := flag.Duration("request-timeout", defFetcherConfig.RequestTimeout,
+		"timeout of connecting and waiting for the response headers")
+	totalTimeout := flag.Duration("total-timeout", defFetcherConfig.TotalTimeout,
+		"timeout of the whole page request including reading of the body")
+	proxy := flag.String("proxy", "", "http(s) proxy url, environment proxy is used if empty")
+	insecure := flag.Bool("insecure", false, "do not verify server certificates")
+	rootCAs := flag.String("root-cas", "", "PEM file with additional trusted root certificates")
+	var headers, cookies stringsFlag
+	flag.Var(&headers, "header", "extra header 'Name: value', repeatable")
+	flag.Var(&cookies, "cookie", "cookie 'name=value', repeatable")
+	ignoreRobots := flag.Bool("ignore-robots", false, "do not consult robots.txt")
+	// Politeness settings
+	defPoliteness := crawler.DefaultPolitenessConfig()
+	workers := flag.Int("workers", defPoliteness.Workers, "number of parallel workers")
+	requestsPerSecond := flag.Float64("rps", defPoliteness.RequestsPerSecond,
+		"requests per second to a single host, 0 - unlimited")
+	maxInFlight := flag.Int("max-in-flight", defPoliteness.MaxInFlightPerHost,
+		"parallel requests to a single host, 0 - unlimited")
+	maxAttempts := flag.Int("max-attempts", crawler.DEFAULT_MAX_ATTEMPTS,
+		"attempts to get a page on timeouts, connection resets, 5xx and 429")
+	// Crawling budget
+	maxDepth := flag.Int("max-depth", 0, "the deepest level to crawl, 0 - unlimited")
+	maxPages := flag.Int("max-pages", 0, "pages to crawl in total, 0 - unlimited")
+	maxPagesPerHost := flag.Int("max-pages-per-host", 0, "pages to crawl from a single host, 0 - unlimited")
+	maxDuration := flag.Duration("max-duration", 0, "crawling time, 0 - unlimited")
+	maxBytes := flag.Int64("max-bytes", 0, "bytes to download in total, 0 - unlimited")
+	// Url normalization
+	trailingSlash := flag.String("trailing-slash", normalizer.TRAILING_SLASH_KEEP,
+		"trailing slash policy: keep, add or remove")
+	// Links
+	noFollowPolicy := flag.String("nofollow", crawler.NOFOLLOW_FOLLOW,
+		"nofollow links policy: follow, record(do not crawl) or ignore")
+	checkLinks := flag.Bool("check-links", false, "check assets and external links after the crawling")
+	checkLinksWorkers := flag.Int("check-links-workers", defPoliteness.Workers, "number of parallel link checkers")
+	checkLinksRps := flag.Float64("check-links-rps", defPoliteness.RequestsPerSecond,
+		"link checking requests per second to a single host, 0 - unlimited")
+	distinctContent := flag.Bool("distinct-content", false,
+		"list only the first page of the pages with the same or nearly the same content")
+	// Content
+	mimeTypes := flag.String("mime-types", strings.Join(crawler.DEFAULT_HTML_MIME_TYPES, ","),
+		"comma separated mime types of the pages to parse, 'type/*' allowed, others are recorded as resources")
+	maxBodySize := flag.Int64("max-body-size", crawler.MAX_PAGE_SIZE, "page body bytes to parse")
+	// Rendering
+	defChromeConfig := crawler.DefaultChromeConfig()
+	renderer := flag.String("renderer", crawler.RENDERER_STATIC, "static or chrome(headless rendering of JavaScript)")
+	chromePath := flag.String("chrome-path", "", "chrome binary, looked up in the PATH if empty")
+	renderWait := flag.String("render-wait", defChromeConfig.WaitFor,
+		"condition to wait for before taking the rendered page: load, networkIdle or css selector")
+	renderTimeout := flag.Duration("render-timeout", defChromeConfig.Timeout, "rendering time of a single page")
+	renderTabs := flag.Int("render-tabs", defChromeConfig.MaxTabs, "pages rendered at once")
+	flag.Parse()
+
 	// Input variations
 	//url := "https://beteastsports.com/"
 	url := "https://www.sportintan.com/"
@@ -49,15 +120,83 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Construct fetcher
+	fetcherConfig := crawler.FetcherConfig{
+		RequestTimeout:     *requestTimeout,
+		TotalTimeout:       *totalTimeout,
+		UserAgent:          *userAgent,
+		Headers:            make(map[string]string),
+		ProxyUrl:           *proxy,
+		InsecureSkipVerify: *insecure,
+		RootCAsFile:        *rootCAs,
+	}
+	for _, header := range headers {
+		nameValue := strings.SplitN(header, ":", 2)
+		if len(nameValue) != 2 {
+			log.Println("Broken header: \"" + header + "\" skipping...")
+			continue
+		}
+		fetcherConfig.Headers[strings.TrimSpace(nameValue[0])] = strings.TrimSpace(nameValue[1])
+	}
+	for _, cookie := range cookies {
+		nameValue := strings.SplitN(cookie, "=", 2)
+		if len(nameValue) != 2 {
+			log.Println("Broken cookie: \"" + cookie + "\" skipping...")
+			continue
+		}
+		fetcherConfig.Cookies = append(fetcherConfig.Cookies, &http.Cookie{
+			Name:  strings.TrimSpace(nameValue[0]),
+			Value: strings.TrimSpace(nameValue[1]),
+		})
+	}
+	fetcher, err := crawler.NewFetcher(fetcherConfig)
+	utils.CheckError(err)
+	crwlr := crawler.NewCrawler(includeSubdomains, validtr, fetcher)
+	crwlr.IgnoreRobots = *ignoreRobots
+	crwlr.Politeness.Workers = *workers
+	crwlr.Politeness.RequestsPerSecond = *requestsPerSecond
+	crwlr.Politeness.MaxInFlightPerHost = *maxInFlight
+	crwlr.Retry.MaxAttempts = *maxAttempts
+	normalizerRules := normalizer.DefaultRules()
+	normalizerRules.TrailingSlash = *trailingSlash
+	crwlr.Normalizer = normalizer.NewNormalizer(normalizerRules)
+	crwlr.NoFollowPolicy = *noFollowPolicy
+	crwlr.AllowedMimeTypes = utils.TrimArray(strings.Split(*mimeTypes, ","))
+	crwlr.MaxBodySize = *maxBodySize
+	crwlr.Budget = crawler.Budget{
+		MaxDepth:        *maxDepth,
+		MaxPages:        *maxPages,
+		MaxPagesPerHost: *maxPagesPerHost,
+		MaxDuration:     *maxDuration,
+		MaxBytes:        *maxBytes,
+	}
+
+	if *renderer == crawler.RENDERER_CHROME {
+		chromeRenderer, err := crawler.NewChromeRenderer(crawler.ChromeConfig{
+			ExecPath: *chromePath,
+			WaitFor:  *renderWait,
+			Timeout:  *renderTimeout,
+			MaxTabs:  *renderTabs,
+		}.WithFetcherConfig(fetcherConfig))
+		utils.CheckError(err)
+		defer chromeRenderer.Close()
+		crwlr.Renderer = chromeRenderer
+	}
+
 	// Read sitemap
 	linksToCrawl := []string{url}
 
-	sitemap, err := crawler.GetLinksFromSitemap(url)
+	sitemap, err := crwlr.GetLinksFromSitemap(context.Background(), url)
 	if err == nil {
 		linksToCrawl = utils.UniqueStringSlice(append(sitemap, url))
 	}
+	linksToCrawl = crwlr.FilterDisallowedByRobots(context.Background(), linksToCrawl)
 	// Crawl specified url
-	crawledLevels := crawler.Crawl(linksToCrawl, []string{}, []crawler.CrawledLevel{}, includeSubdomains, validtr)
+	crawledLevels, _ := crwlr.Crawl(context.Background(), linksToCrawl)
+
+	if stopReasons := crwlr.AppendRobotsStopReason(crawler.StopReasons(crawledLevels)); len(stopReasons) > 0 {
+		log.Println("Crawling was stopped by: ", strings.Join(stopReasons, ", "))
+	}
 
 	// Get execution time in ms
 	executionTime := time.Now().Sub(start).Nanoseconds() / 1E+6
@@ -74,12 +213,72 @@ func main() {
 	err = utils.WriteToFileAndClose(file, marshaled)
 	utils.CheckError(err)
 
+	// Create the file for sitemap coverage report
+	coverageReport := crwlr.BuildCoverageReport(sitemap, crawledLevels)
+	marshaled, err = json.MarshalIndent(coverageReport, "", "\t")
+	utils.CheckError(err)
+	file, err = utils.CreateUniqResultingFile(url, "-coverage.json")
+	utils.CheckError(err)
+	err = utils.WriteToFileAndClose(file, marshaled)
+	utils.CheckError(err)
+
+	// Create the file for site assets inventory
+	marshaled, err = json.MarshalIndent(crawler.AssetInventory(crawledLevels), "", "\t")
+	utils.CheckError(err)
+	file, err = utils.CreateUniqResultingFile(url, "-assets.json")
+	utils.CheckError(err)
+	err = utils.WriteToFileAndClose(file, marshaled)
+	utils.CheckError(err)
+
+	// Create the file for redirect chains report
+	marshaled, err = json.MarshalIndent(crawler.BuildRedirectReport(crawledLevels), "", "\t")
+	utils.CheckError(err)
+	file, err = utils.CreateUniqResultingFile(url, "-redirects.json")
+	utils.CheckError(err)
+	err = utils.WriteToFileAndClose(file, marshaled)
+	utils.CheckError(err)
+
+	// Create the file for duplicated content groups
+	marshaled, err = json.MarshalIndent(crawler.FindDuplicates(crawledLevels, crawler.DEFAULT_SIMHASH_DISTANCE), "", "\t")
+	utils.CheckError(err)
+	file, err = utils.CreateUniqResultingFile(url, "-duplicates.json")
+	utils.CheckError(err)
+	err = utils.WriteToFileAndClose(file, marshaled)
+	utils.CheckError(err)
+
+	// Create the file for bandwidth report
+	bandwidthReport := crawler.BuildBandwidthReport(crawledLevels)
+	marshaled, err = json.MarshalIndent(bandwidthReport, "", "\t")
+	utils.CheckError(err)
+	file, err = utils.CreateUniqResultingFile(url, "-bandwidth.json")
+	utils.CheckError(err)
+	err = utils.WriteToFileAndClose(file, marshaled)
+	utils.CheckError(err)
+
+	// Create the file for links checking report
+	if *checkLinks {
+		checkPoliteness := crawler.DefaultPolitenessConfig()
+		checkPoliteness.Workers = *checkLinksWorkers
+		checkPoliteness.RequestsPerSecond = *checkLinksRps
+		linkCheckReport, _ := crwlr.CheckLinks(context.Background(), crawledLevels, checkPoliteness)
+		marshaled, err = json.MarshalIndent(linkCheckReport, "", "\t")
+		utils.CheckError(err)
+		file, err = utils.CreateUniqResultingFile(url, "-links-check.json")
+		utils.CheckError(err)
+		err = utils.WriteToFileAndClose(file, marshaled)
+		utils.CheckError(err)
+	}
+
 	// Create the file for crawled links only file
-	crawledLinks := crawler.ExtractUniqueLinks(crawledLevels)
+	crawledLinks := crwlr.ExtractUniqueLinks(crawledLevels)
+	if *distinctContent {
+		crawledLinks = crwlr.ExtractDistinctContentLinks(crawledLevels, crawler.DEFAULT_SIMHASH_DISTANCE)
+	}
 	f, err := utils.CreateUniqResultingFile(url, "-links-only.txt")
 	utils.CheckError(err)
 	err = utils.WriteToFileAndClose(f, []byte(strings.Join(crawledLinks, "\n")))
 	utils.CheckError(err)
 
+	log.Println("Transferred: ", bandwidthReport.TransferBytes, " bytes, decoded: ", bandwidthReport.DecodedBytes, " bytes")
 	log.Println("Execution time: ", executionTime, " ms")
 }
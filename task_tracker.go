@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"go-crawler/crawler"
 	"go-crawler/dao/mysqldao"
+	"go-crawler/normalizer"
+	"go-crawler/rules"
 	"go-crawler/utils"
 	"go-crawler/validator"
 	"log"
@@ -12,11 +16,50 @@ import (
 	"time"
 )
 
+const (
+	TASK_WATCH_INTERVAL = 3 * time.Second
+)
+
+// Polls the crawling task state while it's being crawled and cancels the crawling
+// as soon as the task is hidden, cancelled or removed from the web gui
+func watchCrawlingTask(ctx context.Context, cancel context.CancelFunc, taskId int, connection *sql.DB) {
+	ticker := time.NewTicker(TASK_WATCH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		task, err := mysqldao.GetCrawlingTaskById(taskId, connection)
+		if err == sql.ErrNoRows {
+			log.Print("[task_tracker]\tCrawling task has been removed, cancelling, task id: ", taskId)
+			cancel()
+			return
+		} else if err != nil {
+			log.Print("[task_tracker]\tFailed to check crawling task state: ", err.Error(), ", task id: ", taskId)
+			continue
+		}
+
+		if task.Hidden || task.Status == mysqldao.CANCELLED {
+			log.Print("[task_tracker]\tCrawling task has been hidden or cancelled, cancelling, task id: ", taskId)
+			cancel()
+			return
+		}
+	}
+}
+
 func main() {
 	log.Print("Starting...")
 	connection, err := mysqldao.GetConnection()
 	utils.CheckError(err)
 
+	// The same http client settings for all the tasks
+	fetcher, err := crawler.NewFetcher(crawler.DefaultFetcherConfig())
+	utils.CheckError(err)
+
 	for {
 		// Get current tasks
 		activeTasks, err := mysqldao.GetActiveTasks(connection)
@@ -41,16 +84,20 @@ func main() {
 				log.Print("[task_tracker]\tCrawling task status has been updated to: '", task.Status,
 					"', task id: ", task.Id)
 
-				taskUrl := utils.AddFollowingSlashToUrl(task.Url)
+				// Url normalization, the trailing slash is kept if the policy is not specified
+				normalizerRules := normalizer.DefaultRules()
+				normalizerRules.TrailingSlash = task.TrailingSlash.String
+				taskNormalizer := normalizer.NewNormalizer(normalizerRules)
+				taskUrl, err := taskNormalizer.Normalize(task.Url)
 
 				// Check the url to crawl
-				if !utils.IsUrl(taskUrl) {
+				if err != nil || !utils.IsUrl(taskUrl) {
 					// Update skipped crawling task to DONE
 					task.Status = mysqldao.DONE
 					err = mysqldao.UpdateCrawlingTaskById(task, connection)
 					utils.CheckError(err)
 					log.Print("[task_tracker]\tCrawling task has been skipped because of not valid url: \"",
-						taskUrl, "\", task id: ", task.Id)
+						task.Url, "\", task id: ", task.Id)
 
 					continue
 				}
@@ -71,12 +118,83 @@ func main() {
 				taskValidator := validator.NewValidator(exceptions, allowances)
 				log.Println("[task_tracker]\tValidation rules: ", taskValidator, ", task id: ", task.Id)
 
+				taskCrawler := crawler.NewCrawler(task.IncludeSubdomains, taskValidator, fetcher)
+				taskCrawler.IgnoreRobots = task.IgnoreRobots
+				taskCrawler.Normalizer = taskNormalizer
+				// Per host politeness, defaults are used for not specified values
+				if task.Workers.Valid {
+					taskCrawler.Politeness.Workers = int(task.Workers.Int64)
+				}
+				if task.RequestsPerSecond.Valid {
+					taskCrawler.Politeness.RequestsPerSecond = task.RequestsPerSecond.Float64
+				}
+				if task.MaxInFlightPerHost.Valid {
+					taskCrawler.Politeness.MaxInFlightPerHost = int(task.MaxInFlightPerHost.Int64)
+				}
+				taskCrawler.NoFollowPolicy = task.NoFollowPolicy.String // follow if not specified
+				// Content types to parse, html if not specified
+				if task.AllowedMimeTypes.Valid {
+					taskCrawler.AllowedMimeTypes = utils.TrimArray(strings.Split(task.AllowedMimeTypes.String, ","))
+				}
+				taskCrawler.MaxBodySize = task.MaxBodySize.Int64
+				// Crawling budget, not specified values are unlimited
+				taskCrawler.Budget = crawler.Budget{
+					MaxDepth:        int(task.MaxDepth.Int64),
+					MaxPages:        int(task.MaxPages.Int64),
+					MaxPagesPerHost: int(task.MaxPagesPerHost.Int64),
+					MaxDuration:     time.Duration(task.MaxDurationMs.Int64) * time.Millisecond,
+					MaxBytes:        task.MaxBytes.Int64,
+				}
+
+				// Scraping rules
+				taskRules, err := mysqldao.GetCrawlingTaskRules(task.Id, connection)
+				utils.CheckError(err)
+				if len(taskRules) > 0 {
+					scrapingRules := make([]rules.Rule, 0, len(taskRules))
+					for _, r := range taskRules {
+						scrapingRules = append(scrapingRules, rules.Rule{
+							Name:       r.Name,
+							Type:       r.Type,
+							Expression: r.Expression,
+							Multiple:   r.Multiple,
+						})
+					}
+					taskCrawler.Extractors = append(crawler.DefaultExtractors(), rules.NewExtractor(scrapingRules))
+					log.Print("[task_tracker]\tScraping rules: ", len(scrapingRules), ", task id: ", task.Id)
+				}
+
+				// Rendering of JavaScript sites by headless Chrome, static fetching if it can't be started
+				var chromeRenderer *crawler.ChromeRenderer
+				if task.Renderer.String == crawler.RENDERER_CHROME {
+					chromeConfig := crawler.DefaultChromeConfig().WithFetcherConfig(fetcher.Config())
+					if task.RenderWait.Valid {
+						chromeConfig.WaitFor = task.RenderWait.String
+					}
+					if task.RenderTimeoutMs.Valid {
+						chromeConfig.Timeout = time.Duration(task.RenderTimeoutMs.Int64) * time.Millisecond
+					}
+					if task.RenderMaxTabs.Valid {
+						chromeConfig.MaxTabs = int(task.RenderMaxTabs.Int64)
+					}
+					chromeRenderer, err = crawler.NewChromeRenderer(chromeConfig)
+					if err != nil {
+						log.Print("[task_tracker]\t", err.Error(), ", pages are fetched statically, task id: ", task.Id)
+					} else {
+						taskCrawler.Renderer = chromeRenderer
+						log.Print("[task_tracker]\tPages are rendered by chrome, task id: ", task.Id)
+					}
+				}
+
 				// Perform a task
 				start := time.Now() // get start time
 
+				// Crawl until done or until the task is hidden/cancelled, the seeding included
+				ctx, cancel := context.WithCancel(context.Background())
+				go watchCrawlingTask(ctx, cancel, task.Id, connection)
+
 				linksToCrawl := []string{taskUrl}
 				// Read the sitemap
-				sitemap, err := crawler.GetLinksFromSitemap(taskUrl)
+				sitemap, err := taskCrawler.GetLinksFromSitemap(ctx, taskUrl)
 				if err == nil {
 					linksToCrawl = utils.UniqueStringSlice(append(sitemap, taskUrl))
 				}
@@ -91,15 +209,32 @@ func main() {
 				linksToCrawl = utils.FilterLinksNotInDomain(domain, linksToCrawl, task.IncludeSubdomains)
 				// Filter out image links
 				linksToCrawl = utils.FilterLinksToImages(linksToCrawl)
+				// Filter out links disallowed by robots.txt
+				linksToCrawl = taskCrawler.FilterDisallowedByRobots(ctx, linksToCrawl)
 
-				crawledLevels := crawler.Crawl(linksToCrawl, []string{},
-					[]crawler.CrawledLevel{}, task.IncludeSubdomains, taskValidator)
+				crawledLevels, err := taskCrawler.Crawl(ctx, linksToCrawl)
+				if err == nil {
+					err = ctx.Err() // cancelled while seeding
+				}
+				cancel()
+				if chromeRenderer != nil {
+					chromeRenderer.Close()
+				}
+				if err != nil {
+					// Leave the task as it is, the web gui has already changed it
+					log.Print("[task_tracker]\tCrawling task was interrupted after ", len(crawledLevels),
+						" levels, results are dropped, task id: ", task.Id)
+					continue
+				}
 				end := time.Now()                                      // get end time
 				executionTimeMs := end.Sub(start).Nanoseconds() / 1E+6 // evaluate execution time
 				log.Print("[task_tracker]\tCrawling task was performed, task id: ", task.Id)
 
-				// Update crawled link estimation table
-				crawledLinks := crawler.ExtractUniqueLinks(crawledLevels)
+				// Update crawled link estimation table, duplicated content is estimated once if required
+				crawledLinks := taskCrawler.ExtractUniqueLinks(crawledLevels)
+				if task.CountDistinctContent {
+					crawledLinks = taskCrawler.ExtractDistinctContentLinks(crawledLevels, crawler.DEFAULT_SIMHASH_DISTANCE)
+				}
 				crawledLinks = utils.RemoveEmptyStrings(crawledLinks)
 				sort.Slice(crawledLinks[:], func(i, j int) bool {
 					return crawledLinks[i] < crawledLinks[j]
@@ -123,6 +258,150 @@ func main() {
 				log.Print("[task_tracker]\t'"+mysqldao.CRAWLED_LINK_EST_TABLE+"' table has been appended(", len(linkEstimations),
 					" rows) with results of crawling task with id: ", task.Id)
 
+				// Save scraped data
+				linksData := make([]mysqldao.CrawledLinkData, 0)
+				for link, values := range rules.Values(crawledLevels) {
+					for name, value := range values {
+						for _, v := range value {
+							linksData = append(linksData, mysqldao.CrawledLinkData{
+								CrawlingTaskId: task.Id,
+								Link:           link,
+								Name:           name,
+								Value:          v,
+							})
+						}
+					}
+				}
+				err = mysqldao.InsertIntoCrawledLinkData(linksData, connection)
+				utils.CheckError(err)
+				log.Print("[task_tracker]\t'"+mysqldao.CRAWLED_LINK_DATA_TABLE+"' table has been appended(", len(linksData),
+					" rows) with scraped data of crawling task with id: ", task.Id)
+
+				// Save site assets inventory
+				inventory := crawler.AssetInventory(crawledLevels)
+				assets := make([]mysqldao.CrawlAsset, 0, len(inventory))
+				for _, asset := range inventory {
+					assets = append(assets, mysqldao.CrawlAsset{
+						CrawlingTaskId: task.Id,
+						Url:            asset.Url,
+						Type:           asset.Type,
+						PagesNum:       asset.PagesNum,
+					})
+				}
+				err = mysqldao.InsertIntoCrawlAsset(assets, connection)
+				utils.CheckError(err)
+				log.Print("[task_tracker]\t'"+mysqldao.CRAWL_ASSET_TABLE+"' table has been appended(", len(assets),
+					" rows) with assets of crawling task with id: ", task.Id)
+
+				// Check assets and external links
+				if task.CheckLinks {
+					ctx, cancel := context.WithCancel(context.Background())
+					go watchCrawlingTask(ctx, cancel, task.Id, connection)
+					// Links checking politeness, defaults are used for not specified values
+					checkPoliteness := crawler.DefaultPolitenessConfig()
+					if task.CheckLinksWorkers.Valid {
+						checkPoliteness.Workers = int(task.CheckLinksWorkers.Int64)
+					}
+					if task.CheckLinksRps.Valid {
+						checkPoliteness.RequestsPerSecond = task.CheckLinksRps.Float64
+					}
+					linkCheckReport, err := taskCrawler.CheckLinks(ctx, crawledLevels, checkPoliteness)
+					cancel()
+					if err != nil {
+						log.Print("[task_tracker]\tLinks checking was interrupted after ", linkCheckReport.CheckedNum,
+							" links, task id: ", task.Id)
+					}
+
+					brokenResources := make([]mysqldao.BrokenResource, 0, len(linkCheckReport.Broken))
+					for _, broken := range linkCheckReport.Broken {
+						brokenResources = append(brokenResources, mysqldao.BrokenResource{
+							CrawlingTaskId: task.Id,
+							PageUrl:        broken.PageUrl,
+							Url:            broken.Url,
+							Type:           broken.Type,
+							StatusCode:     sql.NullInt64{Valid: broken.StatusCode != 0, Int64: int64(broken.StatusCode)},
+							Error:          sql.NullString{Valid: broken.Error != "", String: broken.Error},
+						})
+					}
+					err = mysqldao.InsertIntoBrokenResource(brokenResources, connection)
+					utils.CheckError(err)
+					log.Print("[task_tracker]\t'"+mysqldao.BROKEN_RESOURCE_TABLE+"' table has been appended(",
+						len(brokenResources), " rows) with broken resources of crawling task with id: ", task.Id)
+				}
+
+				// Save duplicated content groups
+				duplicates := make([]mysqldao.CrawlDuplicate, 0)
+				for i, group := range crawler.FindDuplicates(crawledLevels, crawler.DEFAULT_SIMHASH_DISTANCE) {
+					for _, link := range group.Urls {
+						duplicates = append(duplicates, mysqldao.CrawlDuplicate{
+							CrawlingTaskId: task.Id,
+							GroupNum:       i + 1,
+							Kind:           group.Kind,
+							Url:            link,
+						})
+					}
+				}
+				err = mysqldao.InsertIntoCrawlDuplicate(duplicates, connection)
+				utils.CheckError(err)
+				log.Print("[task_tracker]\t'"+mysqldao.CRAWL_DUPLICATE_TABLE+"' table has been appended(", len(duplicates),
+					" rows) with duplicated content of crawling task with id: ", task.Id)
+
+				// Save redirect chains
+				redirectReport := crawler.BuildRedirectReport(crawledLevels)
+				redirects := make([]mysqldao.CrawlRedirect, 0)
+				for _, issues := range [][]crawler.RedirectIssue{redirectReport.LongChains,
+					redirectReport.CrossDomain, redirectReport.Loops} {
+					for _, issue := range issues {
+						chain, err := json.Marshal(issue.Chain)
+						utils.CheckError(err)
+						redirects = append(redirects, mysqldao.CrawlRedirect{
+							CrawlingTaskId: task.Id,
+							Url:            issue.Url,
+							FinalUrl:       sql.NullString{Valid: issue.FinalUrl != "", String: issue.FinalUrl},
+							Kind:           issue.Kind,
+							Hops:           issue.Hops,
+							Chain:          string(chain),
+						})
+					}
+				}
+				err = mysqldao.InsertIntoCrawlRedirect(redirects, connection)
+				utils.CheckError(err)
+				log.Print("[task_tracker]\t'"+mysqldao.CRAWL_REDIRECT_TABLE+"' table has been appended(", len(redirects),
+					" rows) with redirects of crawling task with id: ", task.Id)
+
+				// Save sitemap coverage report
+				coverageReport := taskCrawler.BuildCoverageReport(sitemap, crawledLevels)
+				coverage := make([]mysqldao.CrawlCoverage, 0, len(coverageReport.Issues))
+				for _, issue := range coverageReport.Issues {
+					coverage = append(coverage, mysqldao.CrawlCoverage{
+						CrawlingTaskId: task.Id,
+						Link:           issue.Url,
+						Kind:           issue.Kind,
+						Details:        sql.NullString{Valid: issue.Details != "", String: issue.Details},
+					})
+				}
+				err = mysqldao.InsertIntoCrawlCoverage(coverage, connection)
+				utils.CheckError(err)
+				log.Print("[task_tracker]\t'"+mysqldao.CRAWL_COVERAGE_TABLE+"' table has been appended(", len(coverage),
+					" rows) with sitemap coverage of crawling task with id: ", task.Id)
+
+				// Save bandwidth of the crawling, totals are saved with the task status
+				bandwidthReport := crawler.BuildBandwidthReport(crawledLevels)
+				heavyPages := make([]mysqldao.CrawlHeavyPage, 0, len(bandwidthReport.HeaviestPages))
+				for _, page := range bandwidthReport.HeaviestPages {
+					heavyPages = append(heavyPages, mysqldao.CrawlHeavyPage{
+						CrawlingTaskId: task.Id,
+						Url:            page.Url,
+						TransferSize:   page.TransferSize,
+						Size:           page.Size,
+						Encoding:       page.Encoding,
+					})
+				}
+				err = mysqldao.InsertIntoCrawlHeavyPage(heavyPages, connection)
+				utils.CheckError(err)
+				log.Print("[task_tracker]\t'"+mysqldao.CRAWL_HEAVY_PAGE_TABLE+"' table has been appended(", len(heavyPages),
+					" rows) with the heaviest pages of crawling task with id: ", task.Id)
+
 				// Update estimator table
 				nullCrawledLinksNum := sql.NullInt64{
 					Valid: true,
@@ -144,6 +423,14 @@ func main() {
 
 				// Update crawling task status
 				task.Status = mysqldao.DONE
+				task.TransferBytes = sql.NullInt64{Valid: true, Int64: bandwidthReport.TransferBytes}
+				task.DecodedBytes = sql.NullInt64{Valid: true, Int64: bandwidthReport.DecodedBytes}
+				stopReasons := taskCrawler.AppendRobotsStopReason(crawler.StopReasons(crawledLevels))
+				if len(stopReasons) > 0 {
+					task.StopReason = sql.NullString{Valid: true, String: strings.Join(stopReasons, ",")}
+					log.Print("[task_tracker]\tCrawling was stopped by: ", task.StopReason.String,
+						", task id: ", task.Id)
+				}
 				err = mysqldao.UpdateCrawlingTaskById(task, connection)
 				utils.CheckError(err)
 				log.Print("[task_tracker]\tCrawling task status has been updated to: '"+task.Status+